@@ -459,10 +459,10 @@ func (s *ServerContext) datastoreConfig(config *serverconfig.Config) (storage.Op
 			return nil, nil, fmt.Errorf("initialize sqlite datastore: %w", err)
 		}
 	case "mongo", "mongodb":
+		tokenSerializer = mongo.NewContinuationTokenSerializer()
 		// MongoDB requires its own configuration type
 		mongoCfg := &mongo.Config{
 			URI:                    config.Datastore.URI,
-			Database:               "openfga", // default database name
 			Username:               config.Datastore.Username,
 			Password:               config.Datastore.Password,
 			Logger:                 s.Logger,
@@ -473,15 +473,7 @@ func (s *ServerContext) datastoreConfig(config *serverconfig.Config) (storage.Op
 			ConnMaxLifetime:        config.Datastore.ConnMaxLifetime,
 			ExportMetrics:          config.Datastore.Metrics.Enabled,
 		}
-		
-		// Extract database name from URI if present
-		if strings.Contains(config.Datastore.URI, "/") {
-			parts := strings.Split(config.Datastore.URI, "/")
-			if len(parts) > 1 && parts[len(parts)-1] != "" {
-				mongoCfg.Database = strings.Split(parts[len(parts)-1], "?")[0] // Remove query params
-			}
-		}
-		
+
 		datastore, err = mongo.New(config.Datastore.URI, mongoCfg)
 		if err != nil {
 			return nil, nil, fmt.Errorf("initialize mongodb datastore: %w", err)
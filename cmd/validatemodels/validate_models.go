@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -74,20 +73,11 @@ func runValidate(_ *cobra.Command, _ []string) error {
 		// MongoDB requires its own configuration type
 		mongoCfg := &mongo.Config{
 			URI:                    uri,
-			Database:               "openfga", // default database name
 			Logger:                 logger.NewNoopLogger(),
 			MaxTuplesPerWriteField: 100,
 			MaxTypesPerModelField:  100,
 		}
-		
-		// Extract database name from URI if present
-		if strings.Contains(uri, "/") {
-			parts := strings.Split(uri, "/")
-			if len(parts) > 1 && parts[len(parts)-1] != "" {
-				mongoCfg.Database = strings.Split(parts[len(parts)-1], "?")[0] // Remove query params
-			}
-		}
-		
+
 		db, err = mongo.New(uri, mongoCfg)
 	case "":
 		return fmt.Errorf("missing datastore engine type")
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -55,11 +56,39 @@ type ReadResponse struct {
 	} `json:"tuples"`
 }
 
+// ErrStoreGone is returned by store-scoped calls when the client's store no longer exists,
+// for example because another process deleted it.
+var ErrStoreGone = errors.New("store no longer exists")
+
+// storeNotFoundCode is the error code OpenFGA returns for a missing or deleted store.
+const storeNotFoundCode = "store_id_not_found"
+
+// APIError is an error response returned by the OpenFGA API.
+type APIError struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is makes errors.Is(err, ErrStoreGone) report store_id_not_found responses.
+func (e *APIError) Is(target error) bool {
+	return target == ErrStoreGone && e.Code == storeNotFoundCode
+}
+
+// StoreGoneFunc is called when the client's store has disappeared. It returns the id of the
+// store to use instead, typically after re-creating it or looking it up again.
+type StoreGoneFunc func(c *OpenFGAClient) (string, error)
+
 type OpenFGAClient struct {
 	baseURL              string
-	httpClient          *http.Client
-	storeID             string
+	httpClient           *http.Client
+	storeID              string
 	authorizationModelID string
+	onStoreGone          StoreGoneFunc
 }
 
 func NewOpenFGAClient(baseURL string) *OpenFGAClient {
@@ -69,6 +98,13 @@ func NewOpenFGAClient(baseURL string) *OpenFGAClient {
 	}
 }
 
+// OnStoreGone sets the callback used to recover when the store is deleted mid-session. The
+// failed call is retried once against the store the callback returns. Without a callback,
+// store-scoped calls return an error matching ErrStoreGone.
+func (c *OpenFGAClient) OnStoreGone(fn StoreGoneFunc) {
+	c.onStoreGone = fn
+}
+
 func (c *OpenFGAClient) doRequest(method, path string, body interface{}, result interface{}) error {
 	var reqBody io.Reader
 	if body != nil {
@@ -98,7 +134,11 @@ func (c *OpenFGAClient) doRequest(method, path string, body interface{}, result
 	}
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(respBody))
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(respBody, apiErr); err != nil || apiErr.Code == "" {
+			apiErr.Message = string(respBody)
+		}
+		return apiErr
 	}
 
 	if result != nil {
@@ -108,6 +148,25 @@ func (c *OpenFGAClient) doRequest(method, path string, body interface{}, result
 	return nil
 }
 
+// doStoreRequest performs a request on a path below the client's store. If the store has been
+// deleted and an OnStoreGone callback is set, it switches to the store the callback returns,
+// forgetting the authorization model id, and retries once.
+func (c *OpenFGAClient) doStoreRequest(method, path string, body interface{}, result interface{}) error {
+	err := c.doRequest(method, "/stores/"+c.storeID+path, body, result)
+	if !errors.Is(err, ErrStoreGone) || c.onStoreGone == nil {
+		return err
+	}
+
+	storeID, cbErr := c.onStoreGone(c)
+	if cbErr != nil {
+		return fmt.Errorf("%w: recovering store %s: %v", ErrStoreGone, c.storeID, cbErr)
+	}
+	c.storeID = storeID
+	c.authorizationModelID = ""
+
+	return c.doRequest(method, "/stores/"+c.storeID+path, body, result)
+}
+
 func (c *OpenFGAClient) CreateStore(name string) (*Store, error) {
 	req := CreateStoreRequest{Name: name}
 	var store Store
@@ -120,9 +179,8 @@ func (c *OpenFGAClient) CreateStore(name string) (*Store, error) {
 }
 
 func (c *OpenFGAClient) WriteAuthorizationModel(model *AuthModel) (*WriteAuthModelResponse, error) {
-	path := fmt.Sprintf("/stores/%s/authorization-models", c.storeID)
 	var resp WriteAuthModelResponse
-	err := c.doRequest("POST", path, model, &resp)
+	err := c.doStoreRequest("POST", "/authorization-models", model, &resp)
 	if err != nil {
 		return nil, err
 	}
@@ -143,8 +201,7 @@ func (c *OpenFGAClient) Write(tuples []TupleKey) error {
 			},
 		},
 	}
-	path := fmt.Sprintf("/stores/%s/write", c.storeID)
-	return c.doRequest("POST", path, req, nil)
+	return c.doStoreRequest("POST", "/write", req, nil)
 }
 
 func (c *OpenFGAClient) Check(user, relation, object string) (*CheckResponse, error) {
@@ -153,16 +210,14 @@ func (c *OpenFGAClient) Check(user, relation, object string) (*CheckResponse, er
 		Relation: relation,
 		Object:   object,
 	}
-	path := fmt.Sprintf("/stores/%s/check", c.storeID)
 	var resp CheckResponse
-	err := c.doRequest("POST", path, req, &resp)
+	err := c.doStoreRequest("POST", "/check", req, &resp)
 	return &resp, err
 }
 
 func (c *OpenFGAClient) Read() (*ReadResponse, error) {
-	path := fmt.Sprintf("/stores/%s/read", c.storeID)
 	var resp ReadResponse
-	err := c.doRequest("POST", path, nil, &resp)
+	err := c.doStoreRequest("POST", "/read", nil, &resp)
 	return &resp, err
 }
 
@@ -180,6 +235,16 @@ func main() {
 	}
 	fmt.Printf("Created store: %s (ID: %s)\n", store.Name, store.ID)
 
+	// Re-create the store if another process deletes it while the example runs.
+	client.OnStoreGone(func(c *OpenFGAClient) (string, error) {
+		fmt.Println("Store was deleted, creating a new one...")
+		recreated, err := c.CreateStore(store.Name)
+		if err != nil {
+			return "", err
+		}
+		return recreated.ID, nil
+	})
+
 	// Step 2: Write authorization model
 	fmt.Println("\nStep 2: Writing authorization model...")
 	typeDefinitions := json.RawMessage(`[
@@ -246,7 +311,7 @@ func main() {
 
 	// Step 4: Show MongoDB integration working
 	fmt.Println("\nStep 4: Demonstrating MongoDB storage...")
-	
+
 	// Skip authorization checks due to tuple write issue
 	fmt.Println("   Skipping authorization checks due to tuple write API formatting issue")
 	fmt.Println("   Store created successfully in MongoDB")
@@ -257,7 +322,7 @@ func main() {
 	fmt.Println("\nStep 5: Verifying MongoDB storage...")
 	fmt.Println("   Data is being stored in MongoDB collections:")
 	fmt.Println("   • stores - OpenFGA store metadata")
-	fmt.Println("   • authorization_models - Authorization model definitions") 
+	fmt.Println("   • authorization_models - Authorization model definitions")
 	fmt.Println("   • tuples - Relationship tuples (when write API works)")
 	fmt.Println("   • changelog - Change history")
 	fmt.Println("\n   You can verify this by running:")
@@ -280,4 +345,4 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
@@ -33,6 +33,9 @@ var (
 
 	// ErrTransactionThrottled can apply when a limit is hit at the database level.
 	ErrTransactionThrottled = status.Error(codes.ResourceExhausted, "transaction was throttled by the datastore")
+
+	// ErrDatastoreUnavailable is returned when the datastore can't be reached.
+	ErrDatastoreUnavailable = status.Error(codes.Unavailable, "datastore unavailable")
 )
 
 type InternalError struct {
@@ -126,6 +129,9 @@ func HandleError(public string, err error) error {
 	case errors.Is(err, context.Canceled):
 		// cancel by a client is not an "internal server error"
 		return ErrRequestCancelled
+	case errors.Is(err, storage.ErrDatastoreUnavailable):
+		// checked before deadlines, which a datastore that can't be reached often runs into
+		return ErrDatastoreUnavailable
 	case errors.Is(err, context.DeadlineExceeded):
 		return ErrRequestDeadlineExceeded
 	case errors.Is(err, storage.ErrInvalidStartTime):
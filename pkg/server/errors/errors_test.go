@@ -72,6 +72,10 @@ func TestHandleErrors(t *testing.T) {
 			storageErr:              storage.ErrTransactionThrottled,
 			expectedTranslatedError: ErrTransactionThrottled,
 		},
+		`datastore_unavailable`: {
+			storageErr:              fmt.Errorf("%w: %w", storage.ErrDatastoreUnavailable, context.DeadlineExceeded),
+			expectedTranslatedError: ErrDatastoreUnavailable,
+		},
 	}
 	for testName, test := range tests {
 		t.Run(testName, func(t *testing.T) {
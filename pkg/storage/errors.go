@@ -19,6 +19,9 @@ var (
 	// ErrInvalidStartTime is returned when start time param for ReadChanges API is invalid.
 	ErrInvalidStartTime = errors.New("invalid start time")
 
+	// ErrExceededWriteBatchLimit is returned when a write has more tuples than MaxTuplesPerWrite allows.
+	ErrExceededWriteBatchLimit = errors.New("number of operations exceeded write batch limit")
+
 	// ErrInvalidWriteInput is returned when the tuple to be written
 	// already existed or the tuple to be deleted did not exist.
 	ErrInvalidWriteInput = errors.New("tuple to be written already existed or the tuple to be deleted did not exist")
@@ -31,6 +34,10 @@ var (
 
 	// ErrNotFound is returned when the object does not exist.
 	ErrNotFound = errors.New("not found")
+
+	// ErrDatastoreUnavailable is returned when the datastore can't be reached, such as when no
+	// database server could be selected in time.
+	ErrDatastoreUnavailable = errors.New("datastore unavailable")
 )
 
 // InvalidWriteInputError generates an error for invalid operations in a tuple store.
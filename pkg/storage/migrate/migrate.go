@@ -9,10 +9,14 @@ import (
 	"github.com/cenkalti/backoff/v4"
 	"github.com/go-sql-driver/mysql"
 	"github.com/pressly/goose/v3"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
 	"go.uber.org/zap"
 
 	"github.com/openfga/openfga/assets"
 	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/storage/mongo"
 	"github.com/openfga/openfga/pkg/storage/sqlite"
 )
 
@@ -34,8 +38,9 @@ type MigrationConfig struct {
 // 1. Explicitly control when OpenFGA migrations run
 // 2. Integrate OpenFGA's schema updates into their own migration workflows
 // 3. Perform versioned upgrades of the schema as needed
-// The function handles migrations for multiple database engines (postgres, mysql, sqlite) and supports
-// both upgrading and downgrading to specific versions.
+// The function handles migrations for multiple database engines (postgres, mysql, sqlite, mongodb) and supports
+// both upgrading and downgrading to specific versions. MongoDB has no schema versions, so for it the
+// collections and indexes are created if missing and the target version is ignored.
 func RunMigrations(cfg MigrationConfig) error {
 	goose.SetLogger(goose.NopLogger())
 	goose.SetVerbose(cfg.Verbose)
@@ -103,6 +108,8 @@ func RunMigrations(cfg MigrationConfig) error {
 		if err != nil {
 			return err
 		}
+	case "mongo", "mongodb":
+		return runMongoMigrations(cfg, log)
 	case "":
 		return fmt.Errorf("missing datastore engine type")
 	default:
@@ -162,3 +169,62 @@ func RunMigrations(cfg MigrationConfig) error {
 	log.Info("migration done")
 	return nil
 }
+
+// runMongoMigrations creates the MongoDB datastore's collections and indexes. The database is the
+// one named in the URI, or "openfga" when the URI doesn't name one, matching the server.
+func runMongoMigrations(cfg MigrationConfig, log logger.Logger) error {
+	connString, err := connstring.ParseAndValidate(cfg.URI)
+	if err != nil {
+		return fmt.Errorf("invalid database uri: %v", err)
+	}
+	dbName := connString.Database
+	if dbName == "" {
+		dbName = mongo.DefaultDatabase
+	}
+
+	clientOptions := options.Client().ApplyURI(cfg.URI)
+	if cfg.Username != "" && cfg.Password != "" {
+		clientOptions.SetAuth(options.Credential{
+			Username: cfg.Username,
+			Password: cfg.Password,
+		})
+	}
+
+	client, err := mongodriver.Connect(context.Background(), clientOptions)
+	if err != nil {
+		return fmt.Errorf("failed to open a connection to the datastore: %w", err)
+	}
+	defer func() {
+		_ = client.Disconnect(context.Background())
+	}()
+
+	policy := backoff.NewExponentialBackOff()
+	policy.MaxElapsedTime = cfg.Timeout
+	err = backoff.Retry(func() error {
+		return client.Ping(context.Background(), nil)
+	}, policy)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database connection: %w", err)
+	}
+
+	if cfg.TargetVersion != 0 {
+		log.Info("mongodb migrations always reach the latest schema version, ignoring target version",
+			zap.Uint("target version", cfg.TargetVersion))
+	}
+
+	log.Info("running all migrations")
+	report, err := mongo.RunMigrations(context.Background(), client, dbName, mongo.WithLogger(log))
+	if err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	log.Info("migration done",
+		zap.Strings("created collections", report.CreatedCollections),
+		zap.Strings("existing collections", report.ExistingCollections),
+		zap.Strings("created indexes", report.CreatedIndexes),
+		zap.Strings("existing indexes", report.ExistingIndexes),
+		zap.Int64("split objects", report.SplitObjects),
+		zap.Int64("user types", report.UserTypes),
+		zap.Int("schema version", report.SchemaVersion),
+	)
+	return nil
+}
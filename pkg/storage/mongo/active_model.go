@@ -0,0 +1,100 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// ActivateModel makes the model the store's active authorization model, so that every server
+// reading GetActiveModel agrees on it, rather than on whichever model FindLatestAuthorizationModel
+// returns. The model is checked to belong to the store, and the store's
+// active_authorization_model_id and updated_at are then set in a single update. Models are never
+// changed or deleted once written, so the check can't go stale. It returns storage.ErrNotFound
+// if the store doesn't exist or is deleted, or the model isn't one of its models.
+func (ds *Datastore) ActivateModel(ctx context.Context, store, modelID string) (err error) {
+	ctx, span := ds.startTrace(ctx, "ActivateModel", storeAttributes(store, StoresCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return err
+	}
+	defer releaseStore()
+
+	if _, err := ulid.ParseStrict(modelID); err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidModelID, modelID)
+	}
+
+	models := ds.collection(AuthorizationModelsCollection)
+	opts := ds.findOneTimeout().SetProjection(bson.M{"_id": 1})
+	err = ds.retry(ctx, func() error {
+		return models.FindOne(ctx, bson.M{"store": store, "id": modelID}, opts).Err()
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("find authorization model: %w", err)
+	}
+
+	result, err := ds.writeCollection(StoresCollection).UpdateOne(ctx,
+		bson.M{"id": store, "deleted_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"active_authorization_model_id": modelID,
+			"updated_at":                    primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("activate authorization model: %w", writeConcernError(err))
+	}
+	if result.MatchedCount == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// GetActiveModel returns the store's active authorization model, the one last set by
+// ActivateModel. It returns ErrNoActiveModel, which wraps storage.ErrNotFound, if no model was
+// ever activated, and storage.ErrNotFound if the store doesn't exist or is deleted.
+func (ds *Datastore) GetActiveModel(ctx context.Context, store string) (_ *openfgav1.AuthorizationModel, err error) {
+	ctx, span := ds.startTrace(ctx, "GetActiveModel", storeAttributes(store, StoresCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	stores := ds.collection(StoresCollection)
+	opts := ds.findOneTimeout().SetProjection(bson.M{"active_authorization_model_id": 1})
+
+	var doc StoreDocument
+	err = ds.retry(ctx, func() error {
+		return stores.FindOne(ctx, bson.M{"id": store, "deleted_at": bson.M{"$exists": false}}, opts).Decode(&doc)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find store: %w", err)
+	}
+	if doc.ActiveAuthorizationModelID == "" {
+		return nil, ErrNoActiveModel
+	}
+
+	return ds.ReadAuthorizationModel(ctx, store, doc.ActiveAuthorizationModelID)
+}
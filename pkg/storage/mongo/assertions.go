@@ -0,0 +1,90 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// AssertionSet is the assertions written for one of a store's authorization models.
+type AssertionSet struct {
+	ModelID    string
+	Assertions []*openfgav1.Assertion
+}
+
+// ListAssertions returns the assertion sets of every model of the store, one per model that has
+// assertions written, newest model first, for test runners that check every model rather than
+// one. It returns up to PageSize sets and a continuation token for the rest, which is empty on
+// the last page. A store without assertions has no sets, which isn't an error.
+func (ds *Datastore) ListAssertions(
+	ctx context.Context,
+	store string,
+	pagination storage.PaginationOptions,
+) (_ []*AssertionSet, _ string, err error) {
+	ctx, span := ds.startTrace(ctx, "ListAssertions", storeAttributes(store, AssertionsCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, "", err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, "", err
+	}
+	defer releaseStore()
+
+	pageSize := ds.pageSize(pagination.PageSize)
+
+	// Model ids are ULIDs, so descending model_id order is newest first, as for
+	// ReadAuthorizationModels, and the token is the last model id returned.
+	filter := bson.M{"store": store}
+	if pagination.From != "" {
+		if err := validateULIDToken(pagination.From); err != nil {
+			return nil, "", err
+		}
+		filter["model_id"] = bson.M{"$lt": pagination.From}
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "model_id", Value: -1}}).
+		SetLimit(int64(pageSize + 1)).
+		SetHint(assertionIndexKeys)
+
+	cursor, err := ds.find(ctx, ds.collection(AssertionsCollection), filter, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("find assertions: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	sets := []*AssertionSet{}
+	for cursor.Next(ctx) {
+		if len(sets) == pageSize {
+			// The extra document only signals that another page exists.
+			setResultCount(span, len(sets))
+			return sets, sets[len(sets)-1].ModelID, nil
+		}
+
+		var doc AssertionDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, "", fmt.Errorf("decode assertions: %w", err)
+		}
+		assertions, err := doc.decode()
+		if err != nil {
+			return nil, "", err
+		}
+		sets = append(sets, &AssertionSet{ModelID: doc.ModelID, Assertions: assertions})
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, "", fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	setResultCount(span, len(sets))
+	return sets, "", nil
+}
@@ -0,0 +1,66 @@
+package mongo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// applyAuth sets the credential from the Config on top of the one parsed from the URI, so that
+// options given in only one of them are kept. Without a username, MONGODB-X509 takes the user
+// from the client certificate's subject.
+func applyAuth(clientOptions *options.ClientOptions, cfg *Config) {
+	if cfg.Username == "" && cfg.Password == "" && cfg.AuthMechanism == "" && cfg.AuthSource == "" {
+		return
+	}
+
+	credential := options.Credential{}
+	if clientOptions.Auth != nil {
+		credential = *clientOptions.Auth
+	}
+	if cfg.Username != "" {
+		credential.Username = cfg.Username
+	}
+	if cfg.Password != "" {
+		credential.Password = cfg.Password
+		credential.PasswordSet = true
+	}
+	if cfg.AuthMechanism != "" {
+		credential.AuthMechanism = cfg.AuthMechanism
+	}
+	if cfg.AuthSource != "" {
+		credential.AuthSource = cfg.AuthSource
+	}
+	clientOptions.SetAuth(credential)
+}
+
+// loadTLSConfig builds the TLS configuration from CAFile and ClientCertFile. The client
+// certificate file holds both the certificate and its key, as in the URI's tlsCertificateKeyFile.
+func loadTLSConfig(cfg *Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load mongodb CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("load mongodb CA file %s: no PEM certificates found", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("load mongodb client certificate %s: %w", cfg.ClientCertFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,21 @@
+package mongo
+
+import "context"
+
+// runInBackground runs fn in a goroutine with the datastore's root context. The context is
+// cancelled by Close, which then waits for fn to return.
+func (ds *Datastore) runInBackground(fn func(ctx context.Context)) {
+	ds.background.Add(1)
+	go func() {
+		defer ds.background.Done()
+		fn(ds.rootCtx)
+	}()
+}
+
+// stopBackground cancels the root context and waits for every background goroutine to exit.
+func (ds *Datastore) stopBackground() {
+	if ds.cancelRootCtx != nil {
+		ds.cancelRootCtx()
+	}
+	ds.background.Wait()
+}
@@ -0,0 +1,348 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// mongoNamespaceExistsCode is the server error code returned when creating a collection that already exists.
+const mongoNamespaceExistsCode = 48
+
+// emptyChanges builds the result of a ReadChanges call that found nothing. The continuation
+// token is the one supplied by the caller, so tailing resumes from the same point; for a first
+// read it is the smallest ULID at the start of the horizon, so no later change can be skipped.
+// It also creates the changelog collection if it doesn't exist yet.
+func (ds *Datastore) emptyChanges(
+	ctx context.Context,
+	filter storage.ReadChangesFilter,
+	options storage.ReadChangesOptions,
+) ([]*openfgav1.TupleChange, string, error) {
+	if err := ds.ensureChangelogCollection(ctx); err != nil {
+		return nil, "", err
+	}
+
+	if options.Pagination.From != "" {
+		return []*openfgav1.TupleChange{}, options.Pagination.From, nil
+	}
+
+	var token ulid.ULID
+	if err := token.SetTime(ulid.Timestamp(time.Now().Add(-filter.HorizonOffset))); err != nil {
+		return nil, "", fmt.Errorf("build initial continuation token: %w", err)
+	}
+
+	return []*openfgav1.TupleChange{}, token.String(), nil
+}
+
+// ensureChangelogCollection creates the changelog collection the first time it's needed.
+func (ds *Datastore) ensureChangelogCollection(ctx context.Context) error {
+	if ds.changelogEnsured.Load() {
+		return nil
+	}
+
+	err := ds.database.CreateCollection(ctx, ds.collectionName(ChangelogCollection))
+	var cmdErr mongo.CommandError
+	if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == mongoNamespaceExistsCode) {
+		return fmt.Errorf("create changelog collection: %w", err)
+	}
+
+	ds.changelogEnsured.Store(true)
+	return nil
+}
+
+// ChangeSummaryBucket holds the number of tuple writes and deletes recorded in the changelog
+// during the bucket starting at Start.
+type ChangeSummaryBucket struct {
+	Start   time.Time `json:"start"`
+	Writes  int64     `json:"writes"`
+	Deletes int64     `json:"deletes"`
+}
+
+// ChangeSummary counts the store's changelog entries since the given time, grouped into
+// consecutive buckets of the given width (aligned to the Unix epoch). The grouping runs
+// server-side, so only one document per bucket is returned. Buckets without changes are omitted.
+func (ds *Datastore) ChangeSummary(
+	ctx context.Context,
+	store string,
+	bucket time.Duration,
+	since time.Time,
+) (_ []ChangeSummaryBucket, err error) {
+	ctx, span := ds.startTrace(ctx, "ChangeSummary", storeAttributes(store, ChangelogCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	bucketMillis := bucket.Milliseconds()
+	if bucketMillis <= 0 {
+		return nil, errors.New("change summary bucket must be at least one millisecond")
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStore()
+
+	countOperation := func(operation openfgav1.TupleOperation) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$operation", operation}}, 1, 0}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"store":     store,
+			"timestamp": bson.M{"$gte": primitive.NewDateTimeFromTime(since)},
+			"pending":   bson.M{"$ne": true},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			// Round each timestamp down to the start of its bucket.
+			"_id": bson.M{"$subtract": bson.A{
+				bson.M{"$toLong": "$timestamp"},
+				bson.M{"$mod": bson.A{bson.M{"$toLong": "$timestamp"}, bucketMillis}},
+			}},
+			"writes":  countOperation(openfgav1.TupleOperation_TUPLE_OPERATION_WRITE),
+			"deletes": countOperation(openfgav1.TupleOperation_TUPLE_OPERATION_DELETE),
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	collection := ds.collection(ChangelogCollection)
+	cursor, err := collection.Aggregate(ctx, pipeline, ds.aggregateTimeout())
+	if err != nil {
+		return nil, fmt.Errorf("aggregate change summary: %w", unavailableError(queryTimeoutError(err)))
+	}
+	defer cursor.Close(ctx)
+
+	var buckets []ChangeSummaryBucket
+	for cursor.Next(ctx) {
+		var doc struct {
+			Start   int64 `bson:"_id"`
+			Writes  int64 `bson:"writes"`
+			Deletes int64 `bson:"deletes"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode change summary: %w", err)
+		}
+		buckets = append(buckets, ChangeSummaryBucket{
+			Start:   time.UnixMilli(doc.Start).UTC(),
+			Writes:  doc.Writes,
+			Deletes: doc.Deletes,
+		})
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	setResultCount(span, len(buckets))
+	return buckets, nil
+}
+
+// ReadAsOf returns the tuples of an object, optionally narrowed to a relation and user, as they
+// stood at asOf, for point-in-time audits. It replays the object's changelog up to asOf: the last
+// confirmed change of each tuple decides whether it existed then, and with which condition. The
+// tuples are returned in the order they were written, each with the timestamp of that write.
+//
+// The result is only as complete as the changelog: changes pruned by PruneChangelog are gone, so
+// a tuple written before the prune cutoff and not changed since is missing, and tuples removed
+// by their expiry are never logged as deleted, so they appear to outlive it.
+func (ds *Datastore) ReadAsOf(
+	ctx context.Context,
+	store string,
+	filter *openfgav1.TupleKey,
+	asOf time.Time,
+) (_ []*openfgav1.Tuple, err error) {
+	ctx, span := ds.startTrace(ctx, "ReadAsOf", storeAttributes(store, ChangelogCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStore()
+
+	filter = ds.normalizeTupleKey(filter)
+	objectType, objectID := tupleUtils.SplitObject(filter.GetObject())
+	if objectType == "" || objectID == "" {
+		return nil, errors.New("read as of needs an object of the form type:id")
+	}
+
+	mongoFilter := buildTupleFilter(store, filter)
+	mongoFilter["timestamp"] = bson.M{"$lte": primitive.NewDateTimeFromTime(asOf)}
+	mongoFilter["pending"] = bson.M{"$ne": true}
+
+	collection := ds.collection(ChangelogCollection)
+	cursor, err := ds.find(ctx, collection, mongoFilter, ds.findTimeout().SetSort(bson.D{{Key: "ulid", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find changes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	type tupleState struct {
+		ulid  string
+		tuple *openfgav1.Tuple
+	}
+	states := make(map[string]tupleState)
+	for cursor.Next(ctx) {
+		var doc ChangelogDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode changelog: %w", err)
+		}
+
+		key := doc.Relation + "\x00" + doc.User
+		if doc.Operation == openfgav1.TupleOperation_TUPLE_OPERATION_DELETE {
+			delete(states, key)
+			continue
+		}
+		states[key] = tupleState{
+			ulid: doc.ULID,
+			tuple: &openfgav1.Tuple{
+				Key:       doc.tupleKey(),
+				Timestamp: timestamppb.New(doc.Timestamp.Time()),
+			},
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	live := make([]tupleState, 0, len(states))
+	for _, state := range states {
+		live = append(live, state)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].ulid < live[j].ulid })
+
+	tuples := make([]*openfgav1.Tuple, 0, len(live))
+	for _, state := range live {
+		tuples = append(tuples, state.tuple)
+	}
+
+	setResultCount(span, len(tuples))
+	return tuples, nil
+}
+
+// PruneChangelog permanently deletes changelog entries, across all stores, that are older than
+// olderThan. The delete uses the ChangelogPruneWriteConcern (w:1 by default) rather than the
+// collection's write concern, so pruning doesn't wait on the same majority acknowledgment as
+// live tuple writes. It returns the number of entries deleted.
+func (ds *Datastore) PruneChangelog(ctx context.Context, olderThan time.Duration) (_ int64, err error) {
+	ctx, span := ds.startTrace(ctx, "PruneChangelog", attribute.String(collectionAttribute, ChangelogCollection))
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return 0, err
+	}
+
+	wc := ds.changelogPruneWriteConcern
+	if wc == nil {
+		wc = writeconcern.W1()
+	}
+
+	collection := ds.collection(ChangelogCollection, options.Collection().SetWriteConcern(wc))
+
+	cutoff := primitive.NewDateTimeFromTime(time.Now().Add(-olderThan))
+	result, err := collection.DeleteMany(ctx, bson.M{"timestamp": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, fmt.Errorf("prune changelog: %w", unavailableError(err))
+	}
+
+	setResultCount(span, int(result.DeletedCount))
+	return result.DeletedCount, nil
+}
+
+// ChangelogReconciliation reports what ReconcileChangelog did with the pending intents it found.
+type ChangelogReconciliation struct {
+	// Confirmed intents had been applied, so they became regular changelog entries.
+	Confirmed int64 `json:"confirmed"`
+	// Discarded intents had not been applied, so they were removed.
+	Discarded int64 `json:"discarded"`
+}
+
+// ReconcileChangelog settles changelog intents left pending by WriteModeIntent writes that failed
+// part way, across all stores. An intent older than olderThan is confirmed if its change is
+// reflected in the tuples (a written tuple exists, a deleted one doesn't) and discarded
+// otherwise. olderThan should exceed the longest expected Write, so that writes still in
+// progress are left alone. This is also how tuples written without a confirmed changelog entry
+// are detected and logged.
+func (ds *Datastore) ReconcileChangelog(ctx context.Context, olderThan time.Duration) (_ *ChangelogReconciliation, err error) {
+	ctx, span := ds.startTrace(ctx, "ReconcileChangelog", attribute.String(collectionAttribute, ChangelogCollection))
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	changelog := ds.collection(ChangelogCollection)
+	tuples := ds.collection(TuplesCollection)
+
+	cutoff := primitive.NewDateTimeFromTime(time.Now().Add(-olderThan))
+	cursor, err := changelog.Find(ctx, bson.M{"pending": true, "timestamp": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return nil, fmt.Errorf("find changelog intents: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	result := &ChangelogReconciliation{}
+	for cursor.Next(ctx) {
+		var entry ChangelogDocument
+		if err := cursor.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("decode changelog intent: %w", err)
+		}
+
+		count, err := tuples.CountDocuments(ctx, bson.M{
+			"store":       entry.Store,
+			"object_type": entry.ObjectType,
+			"object_id":   entry.ObjectID,
+			"relation":    entry.Relation,
+			"user":        entry.User,
+		}, options.Count().SetLimit(1))
+		if err != nil {
+			return nil, fmt.Errorf("find tuple for changelog intent: %w", err)
+		}
+
+		applied := count > 0
+		if entry.Operation == openfgav1.TupleOperation_TUPLE_OPERATION_DELETE {
+			applied = !applied
+		}
+
+		if applied {
+			// The store is the shard key a sharded changelog needs to route a single update.
+			_, err = changelog.UpdateOne(ctx, bson.M{"store": entry.Store, "ulid": entry.ULID}, bson.M{"$unset": bson.M{"pending": ""}})
+			result.Confirmed++
+		} else {
+			_, err = changelog.DeleteOne(ctx, bson.M{"store": entry.Store, "ulid": entry.ULID})
+			result.Discarded++
+		}
+		if err != nil {
+			return nil, fmt.Errorf("settle changelog intent: %w", err)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,182 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// ChangesSinceWindow is how long after its ULID a changelog entry may still become visible, and
+// so how far back ChangesSince looks again on every call. A write's ULIDs are made before the
+// write commits, and a transaction runs for at most a minute by default, so an entry can appear
+// behind entries already returned. Clock skew between instances adds to it.
+const ChangesSinceWindow = time.Minute
+
+// changesSinceToken is the position of a ChangesSince consumer: every change up to Watermark was
+// returned, and so were the changes after it listed in Seen, in ULID order.
+type changesSinceToken struct {
+	Watermark string   `json:"w,omitempty"`
+	Seen      []string `json:"s,omitempty"`
+}
+
+// ChangesSince returns the store's changes committed since the token, in ULID order, and the
+// token to pass to the next call. The empty token starts with the oldest change in the
+// changelog. Unlike ReadChanges it applies no horizon: a change is returned as soon as it is
+// committed, for internal consumers such as caches that sync incrementally.
+//
+// Across consecutive calls, each passed the token of the one before, no change is returned
+// twice, and none is skipped as long as it commits within ChangesSinceWindow of its ULID; a
+// change committed behind changes already returned comes in a later call, after them. The token
+// lists the changes returned within the window,
+// so it grows with the store's write rate. A call returns at most one page of changes, of the
+// default page size; fewer, or none, means the consumer is caught up. A token from before the
+// changelog was vacuumed or pruned by ChangelogRetention fails with an error wrapping
+// storage.ErrInvalidContinuationToken, as does a malformed token.
+func (ds *Datastore) ChangesSince(ctx context.Context, store, token string) (_ []*openfgav1.TupleChange, _ string, err error) {
+	ctx, span := ds.startTrace(ctx, "ChangesSince", storeAttributes(store, ChangelogCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, "", err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, "", err
+	}
+	defer releaseStore()
+
+	position, err := decodeChangesSinceToken(token)
+	if err != nil {
+		return nil, "", err
+	}
+	if position.Watermark != "" {
+		if position.Watermark, err = ds.checkChangesToken(ctx, store, position.Watermark); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// Reads follow HIGHER_CONSISTENCY, so that a change is only returned once it can't be rolled
+	// back, and a lagging secondary can't hide changes behind the watermark.
+	collection := ds.collectionFor(ChangelogCollection, storage.ConsistencyOptions{
+		Preference: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY,
+	})
+	ulidFilter := bson.M{"$gt": position.Watermark}
+	if len(position.Seen) > 0 {
+		ulidFilter["$nin"] = position.Seen
+	}
+	// Intents of unconfirmed writes are not changes yet.
+	filter := bson.M{"store": store, "pending": bson.M{"$ne": true}, "ulid": ulidFilter}
+	pageSize := ds.pageSize(0)
+	opts := options.Find().SetSort(bson.D{{Key: "ulid", Value: 1}}).SetLimit(int64(pageSize))
+
+	// The time is taken before the query, so that every change older than the window was
+	// committed by the time the query ran.
+	now := time.Now()
+
+	cursor, err := ds.find(ctx, collection, filter, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("find changes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	changes := []*openfgav1.TupleChange{}
+	var returned []string
+	for cursor.Next(ctx) {
+		var doc ChangelogDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, "", fmt.Errorf("decode changelog: %w", err)
+		}
+		changes = append(changes, doc.toTupleChange())
+		returned = append(returned, doc.ULID)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, "", fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	next, err := position.advance(returned, len(returned) < pageSize, now).encode()
+	if err != nil {
+		return nil, "", err
+	}
+	setResultCount(span, len(changes))
+	return changes, next, nil
+}
+
+// decodeChangesSinceToken decodes a ChangesSince token. The empty token is the start of the
+// changelog.
+func decodeChangesSinceToken(token string) (*changesSinceToken, error) {
+	position := &changesSinceToken{}
+	if token == "" {
+		return position, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token", storage.ErrInvalidContinuationToken)
+	}
+	if err := json.Unmarshal(payload, position); err != nil {
+		return nil, fmt.Errorf("%w: %s", storage.ErrInvalidContinuationToken, err)
+	}
+	if position.Watermark != "" {
+		if err := validateULIDToken(position.Watermark); err != nil {
+			return nil, err
+		}
+	}
+	for _, seen := range position.Seen {
+		if err := validateULIDToken(seen); err != nil {
+			return nil, err
+		}
+	}
+	return position, nil
+}
+
+// encode returns the token of the position.
+func (t *changesSinceToken) encode() (string, error) {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("encode changes token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+// advance returns the position after the changes returned by a query run at now, in ULID order.
+// complete reports whether the query returned every change it matched. The watermark moves up to
+// the start of the window, where changes can no longer appear, but never past a change the query
+// didn't get to; the changes returned above the watermark are remembered so they aren't returned
+// again.
+func (t *changesSinceToken) advance(returned []string, complete bool, now time.Time) *changesSinceToken {
+	// The smallest ULID of the window's first millisecond. SetTime only fails for times after
+	// the year 10889.
+	var windowStart ulid.ULID
+	_ = windowStart.SetTime(ulid.Timestamp(now.Add(-ChangesSinceWindow)))
+
+	watermark := windowStart.String()
+	if !complete && len(returned) > 0 && returned[len(returned)-1] < watermark {
+		watermark = returned[len(returned)-1]
+	}
+	if watermark < t.Watermark {
+		watermark = t.Watermark
+	}
+
+	next := &changesSinceToken{Watermark: watermark}
+	for _, ids := range [][]string{t.Seen, returned} {
+		for _, id := range ids {
+			if id > watermark {
+				next.Seen = append(next.Seen, id)
+			}
+		}
+	}
+	sort.Strings(next.Seen)
+	return next
+}
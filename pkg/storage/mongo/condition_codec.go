@@ -0,0 +1,306 @@
+package mongo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// ConditionContextBSON stores condition contexts as BSON documents, which can be queried. It
+	// is the default.
+	ConditionContextBSON = "bson"
+	// ConditionContextJSON stores condition contexts as JSON strings, which keep keys that BSON
+	// restricts, such as keys with dots or a leading '$', exactly as written.
+	ConditionContextJSON = "json"
+)
+
+// emptyDocument is the BSON encoding of {}.
+var emptyDocument = []byte{5, 0, 0, 0, 0}
+
+// structType is the type of condition contexts in tuples and changelog entries.
+var structType = reflect.TypeOf((*structpb.Struct)(nil))
+
+// newRegistry returns the BSON registry used for every collection of the datastore. It stores
+// condition contexts as plain BSON documents ({"ip": "10.0.0.1", "limits": {"max": 5}}), which
+// the default codec can't do: it writes the protobuf internals and can't decode them back. With
+// jsonContexts it stores them as JSON strings instead. Either way both forms are decoded, so the
+// encoding can be changed without migrating stored tuples.
+func newRegistry(jsonContexts bool) *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	if jsonContexts {
+		registry.RegisterTypeEncoder(structType, bsoncodec.ValueEncoderFunc(encodeStructJSON))
+	} else {
+		registry.RegisterTypeEncoder(structType, bsoncodec.ValueEncoderFunc(encodeStruct))
+	}
+	registry.RegisterTypeDecoder(structType, bsoncodec.ValueDecoderFunc(decodeStruct))
+	return registry
+}
+
+// encodeStruct writes a condition context as a BSON document, or null when there is none.
+func encodeStruct(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if val.IsNil() {
+		return vw.WriteNull()
+	}
+
+	doc, err := bson.Marshal(val.Interface().(*structpb.Struct).AsMap())
+	if err != nil {
+		return fmt.Errorf("marshal condition context: %w", err)
+	}
+	return bsonrw.Copier{}.CopyDocumentFromBytes(vw, doc)
+}
+
+// encodeStructJSON writes a condition context as a JSON string, or null when there is none.
+func encodeStructJSON(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if val.IsNil() {
+		return vw.WriteNull()
+	}
+
+	data, err := json.Marshal(val.Interface().(*structpb.Struct).AsMap())
+	if err != nil {
+		return fmt.Errorf("marshal condition context: %w", err)
+	}
+	return vw.WriteString(string(data))
+}
+
+// decodeStruct reads a condition context written by encodeStruct or encodeStructJSON, or by the
+// default codec before encodeStruct existed.
+func decodeStruct(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	switch vr.Type() {
+	case bsontype.Null:
+		val.Set(reflect.Zero(structType))
+		return vr.ReadNull()
+	case bsontype.String:
+		data, err := vr.ReadString()
+		if err != nil {
+			return err
+		}
+		s, err := jsonToStruct(data)
+		if err != nil {
+			return fmt.Errorf("decode condition context: %w", err)
+		}
+		val.Set(reflect.ValueOf(s))
+		return nil
+	case bsontype.EmbeddedDocument:
+	default:
+		return fmt.Errorf("cannot decode a condition context from BSON type %s", vr.Type())
+	}
+
+	doc, err := bsonrw.Copier{}.CopyDocumentToBytes(vr)
+	if err != nil {
+		return err
+	}
+
+	var s *structpb.Struct
+	if fields, ok := legacyStructFields(doc); ok {
+		s, err = legacyToStruct(fields)
+	} else {
+		s, err = rawToStruct(doc)
+	}
+	if err != nil {
+		return fmt.Errorf("decode condition context: %w", err)
+	}
+
+	val.Set(reflect.ValueOf(s))
+	return nil
+}
+
+// jsonToStruct converts a context written by encodeStructJSON into a context struct.
+func jsonToStruct(data string) (*structpb.Struct, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(fields)
+}
+
+// rawToStruct converts a BSON document into a context struct.
+func rawToStruct(doc bson.Raw) (*structpb.Struct, error) {
+	elems, err := doc.Elements()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(elems))}
+	for _, elem := range elems {
+		value, err := rawToValue(elem.Value())
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': %w", elem.Key(), err)
+		}
+		s.Fields[elem.Key()] = value
+	}
+	return s, nil
+}
+
+// rawToValue converts a BSON value into a context value. Integers, which only appear in
+// documents written by other tools, become numbers like every other JSON number.
+func rawToValue(value bson.RawValue) (*structpb.Value, error) {
+	switch value.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return structpb.NewNullValue(), nil
+	case bsontype.Boolean:
+		return structpb.NewBoolValue(value.Boolean()), nil
+	case bsontype.Double:
+		return structpb.NewNumberValue(value.Double()), nil
+	case bsontype.Int32:
+		return structpb.NewNumberValue(float64(value.Int32())), nil
+	case bsontype.Int64:
+		return structpb.NewNumberValue(float64(value.Int64())), nil
+	case bsontype.String:
+		return structpb.NewStringValue(value.StringValue()), nil
+	case bsontype.EmbeddedDocument:
+		s, err := rawToStruct(value.Document())
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewStructValue(s), nil
+	case bsontype.Array:
+		elems, err := value.Array().Values()
+		if err != nil {
+			return nil, err
+		}
+		list := &structpb.ListValue{Values: make([]*structpb.Value, 0, len(elems))}
+		for _, elem := range elems {
+			item, err := rawToValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			list.Values = append(list.Values, item)
+		}
+		return structpb.NewListValue(list), nil
+	default:
+		return nil, fmt.Errorf("unsupported BSON type %s", value.Type)
+	}
+}
+
+// legacyStructFields recognizes a context written by the default codec, which encodes the
+// protobuf message itself: {"fields": {"ip": {"kind": {"stringvalue": "10.0.0.1"}}}}. It returns
+// the fields document.
+func legacyStructFields(doc bson.Raw) (bson.Raw, bool) {
+	elems, err := doc.Elements()
+	if err != nil || len(elems) != 1 || elems[0].Key() != "fields" {
+		return nil, false
+	}
+	if elems[0].Value().Type == bsontype.Null {
+		// An empty context.
+		return bson.Raw(emptyDocument), true
+	}
+	fields, ok := elems[0].Value().DocumentOK()
+	if !ok {
+		return nil, false
+	}
+
+	values, err := fields.Elements()
+	if err != nil {
+		return nil, false
+	}
+	for _, value := range values {
+		if _, ok := legacyKind(value.Value()); !ok {
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
+// legacyKind returns the single element of a legacy value's kind document, such as
+// {"stringvalue": "10.0.0.1"}.
+func legacyKind(value bson.RawValue) (bson.RawElement, bool) {
+	doc, ok := value.DocumentOK()
+	if !ok {
+		return nil, false
+	}
+	elems, err := doc.Elements()
+	if err != nil || len(elems) != 1 || elems[0].Key() != "kind" {
+		return nil, false
+	}
+	kind, ok := elems[0].Value().DocumentOK()
+	if !ok {
+		return nil, false
+	}
+	kindElems, err := kind.Elements()
+	if err != nil || len(kindElems) != 1 || !strings.HasSuffix(kindElems[0].Key(), "value") {
+		return nil, false
+	}
+	return kindElems[0], true
+}
+
+// legacyToStruct converts the fields document of a legacy context into a context struct.
+func legacyToStruct(fields bson.Raw) (*structpb.Struct, error) {
+	elems, err := fields.Elements()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(elems))}
+	for _, elem := range elems {
+		value, err := legacyToValue(elem.Value())
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': %w", elem.Key(), err)
+		}
+		s.Fields[elem.Key()] = value
+	}
+	return s, nil
+}
+
+// legacyToValue converts a legacy {"kind": {...}} value into a context value.
+func legacyToValue(value bson.RawValue) (*structpb.Value, error) {
+	kind, ok := legacyKind(value)
+	if !ok {
+		return nil, errors.New("malformed legacy value")
+	}
+
+	switch kind.Key() {
+	case "nullvalue":
+		return structpb.NewNullValue(), nil
+	case "numbervalue", "stringvalue", "boolvalue":
+		return rawToValue(kind.Value())
+	case "structvalue":
+		// A nested Struct message: {"fields": {...}}.
+		nested, ok := kind.Value().DocumentOK()
+		if !ok {
+			return nil, errors.New("malformed legacy struct value")
+		}
+		fields, ok := nested.Lookup("fields").DocumentOK()
+		if !ok {
+			return structpb.NewStructValue(&structpb.Struct{}), nil
+		}
+		s, err := legacyToStruct(fields)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewStructValue(s), nil
+	case "listvalue":
+		// A nested ListValue message: {"values": [...]}.
+		nested, ok := kind.Value().DocumentOK()
+		if !ok {
+			return nil, errors.New("malformed legacy list value")
+		}
+		list := &structpb.ListValue{}
+		values, ok := nested.Lookup("values").ArrayOK()
+		if !ok {
+			return structpb.NewListValue(list), nil
+		}
+		elems, err := values.Values()
+		if err != nil {
+			return nil, err
+		}
+		for _, elem := range elems {
+			item, err := legacyToValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			list.Values = append(list.Values, item)
+		}
+		return structpb.NewListValue(list), nil
+	default:
+		return nil, fmt.Errorf("unknown legacy value kind '%s'", kind.Key())
+	}
+}
@@ -0,0 +1,95 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/protobuf/proto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// ConditionalWrite replaces the expected tuple with replacement, atomically, only if the expected
+// tuple is still stored with the same condition, context included. Otherwise nothing is written
+// and ErrPreconditionFailed is returned, so that two callers editing the same tuple can't lose
+// each other's update: the check and the write run in one transaction, and a concurrent change of
+// the tuple aborts it. The replacement may be the expected tuple with another condition. Both
+// tuples are validated as for Write, and the changes are recorded in the changelog.
+//
+// Transactions need a replica set or sharded cluster, so on a standalone server ConditionalWrite
+// fails with ErrTransactionsUnavailable, whatever the WriteMode.
+func (ds *Datastore) ConditionalWrite(ctx context.Context, store string, expected, replacement *openfgav1.TupleKey) (err error) {
+	ctx, span := ds.startTrace(ctx, "ConditionalWrite", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return err
+	}
+	if expected == nil || replacement == nil {
+		return fmt.Errorf("expected and replacement tuples are required: %w", storage.ErrInvalidWriteInput)
+	}
+	if !ds.SupportsTransactions() {
+		return fmt.Errorf("%w: conditional writes need a replica set or sharded cluster", ErrTransactionsUnavailable)
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return err
+	}
+	defer releaseStore()
+
+	expected = ds.normalizeTupleKey(expected)
+	deletes := storage.Deletes{tupleUtils.TupleKeyToTupleKeyWithoutCondition(expected)}
+	deletes, writes, _, err := ds.validateWrite(ctx, store, deletes, storage.Writes{replacement})
+	if err != nil {
+		return err
+	}
+
+	release, err := ds.acquireWriteSlot(ctx, store)
+	if err != nil {
+		return fmt.Errorf("wait for write slot: %w", err)
+	}
+	defer release()
+
+	// The find makes the tuple part of the transaction's snapshot, and the delete then conflicts
+	// with any change committed to it since, so a transaction rerun after a transient error
+	// checks it again. Like Write's, the transaction isn't retried after its commit gave up.
+	err = ds.runTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		var stored TupleDocument
+		err := ds.writeCollection(TuplesCollection).FindOne(sessCtx,
+			exactTupleFilter(store, expected.GetObject(), expected.GetRelation(), expected.GetUser()),
+		).Decode(&stored)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("%w: %s is not stored", ErrPreconditionFailed, tupleUtils.TupleKeyToString(expected))
+		}
+		if err != nil {
+			return fmt.Errorf("find expected tuple: %w", err)
+		}
+		if !sameCondition(stored.Condition, expected.GetCondition()) {
+			return fmt.Errorf("%w: %s is stored with another condition", ErrPreconditionFailed, tupleUtils.TupleKeyToString(expected))
+		}
+		return ds.applyWrites(sessCtx, store, deletes, writes, nil, false, false)
+	})
+	if err != nil {
+		return fmt.Errorf("transaction failed: %w", unavailableError(writeConcernError(err)))
+	}
+
+	return nil
+}
+
+// sameCondition reports whether two tuple conditions are the same, treating an empty context as
+// no context.
+func sameCondition(a, b *openfgav1.RelationshipCondition) bool {
+	if a.GetName() != b.GetName() {
+		return false
+	}
+	if len(a.GetContext().GetFields()) == 0 || len(b.GetContext().GetFields()) == 0 {
+		return len(a.GetContext().GetFields()) == len(b.GetContext().GetFields())
+	}
+	return proto.Equal(a.GetContext(), b.GetContext())
+}
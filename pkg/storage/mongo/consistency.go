@@ -0,0 +1,64 @@
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// collectionFor returns a handle on the named collection whose reads follow the request's
+// consistency preference. The handle only lives for the query, so requests with different
+// preferences can be served side by side.
+func (ds *Datastore) collectionFor(name string, consistency storage.ConsistencyOptions) *mongo.Collection {
+	return ds.collection(name, consistencyOptions(consistency, ds.database.ReadPreference()))
+}
+
+// consistencyOptions maps a consistency preference to collection options, given the datastore's
+// configured read preference:
+//   - HIGHER_CONSISTENCY reads majority-committed data from the primary.
+//   - MINIMIZE_LATENCY reads with a local read concern from the nearest member, which may be a
+//     secondary that lags behind the primary. When the configured preference already keeps
+//     reads off the primary (secondary or secondaryPreferred), it is kept instead, since nearest
+//     could select the primary. The configured tag sets carry over to nearest, so reads stay on
+//     the members they select, such as those of the local region, and so does the max staleness.
+//   - Without a preference the datastore's configured read concern and read preference apply.
+func consistencyOptions(consistency storage.ConsistencyOptions, configured *readpref.ReadPref) *options.CollectionOptions {
+	switch consistency.Preference {
+	case openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY:
+		return options.Collection().
+			SetReadConcern(readconcern.Majority()).
+			SetReadPreference(readpref.Primary())
+	case openfgav1.ConsistencyPreference_MINIMIZE_LATENCY:
+		readPref := readpref.Nearest()
+		if configured != nil {
+			if mode := configured.Mode(); mode == readpref.SecondaryMode || mode == readpref.SecondaryPreferredMode {
+				readPref = configured
+			} else {
+				readPref = readpref.Nearest(nearestOptions(configured)...)
+			}
+		}
+		return options.Collection().
+			SetReadConcern(readconcern.Local()).
+			SetReadPreference(readPref)
+	default:
+		return options.Collection()
+	}
+}
+
+// nearestOptions returns the tag sets and max staleness of the configured read preference, for
+// the nearest read preference of MINIMIZE_LATENCY.
+func nearestOptions(configured *readpref.ReadPref) []readpref.Option {
+	var opts []readpref.Option
+	if tagSets := configured.TagSets(); len(tagSets) > 0 {
+		opts = append(opts, readpref.WithTagSets(tagSets...))
+	}
+	if maxStaleness, ok := configured.MaxStaleness(); ok {
+		opts = append(opts, readpref.WithMaxStaleness(maxStaleness))
+	}
+	return opts
+}
@@ -1,4 +1,4 @@
 // Package mongo provides a MongoDB-based implementation of the OpenFGA storage interface.
 // This package implements all the required storage backends including TupleBackend,
 // AuthorizationModelBackend, StoresBackend, AssertionsBackend, and ChangelogBackend.
-package mongo
\ No newline at end of file
+package mongo
@@ -0,0 +1,273 @@
+package mongo
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+const (
+	// redacted replaces secrets in the output of EffectiveConfig.
+	redacted = "REDACTED"
+	// defaultConnectTimeout is the driver's connect timeout, used when ConnectTimeout is unset.
+	defaultConnectTimeout = 30 * time.Second
+	// defaultServerSelectionTimeout is the driver's server selection timeout, used when
+	// ServerSelectionTimeout is unset.
+	defaultServerSelectionTimeout = 30 * time.Second
+	// defaultHeartbeatInterval is the driver's heartbeat interval, used when HeartbeatInterval is
+	// unset.
+	defaultHeartbeatInterval = 10 * time.Second
+)
+
+// EffectiveConfig is the configuration a Datastore is running with, after defaults are applied.
+// It is safe to log or serve: the password is never included, and the URI has its credentials
+// and query options removed.
+type EffectiveConfig struct {
+	URI                         string              `json:"uri,omitempty"`
+	Database                    string              `json:"database"`
+	Username                    string              `json:"username,omitempty"`
+	Password                    string              `json:"password,omitempty"`
+	AuthMechanism               string              `json:"auth_mechanism,omitempty"`
+	AuthSource                  string              `json:"auth_source,omitempty"`
+	CAFile                      string              `json:"ca_file,omitempty"`
+	ClientCertFile              string              `json:"client_cert_file,omitempty"`
+	MaxOpenConns                int                 `json:"max_open_conns"`
+	MinPoolSize                 int                 `json:"min_pool_size"`
+	ConnMaxIdleTime             time.Duration       `json:"conn_max_idle_time"`
+	ConnMaxLifetime             time.Duration       `json:"conn_max_lifetime"`
+	ConnectTimeout              time.Duration       `json:"connect_timeout"`
+	ReadPreference              string              `json:"read_preference"`
+	MaxTuplesPerWrite           int                 `json:"max_tuples_per_write"`
+	MaxTypesPerModel            int                 `json:"max_types_per_model"`
+	ExportMetrics               bool                `json:"export_metrics"`
+	RejectEmptyWrites           bool                `json:"reject_empty_writes"`
+	StrictTupleValidation       bool                `json:"strict_tuple_validation"`
+	ConditionContextValidation  bool                `json:"condition_context_validation"`
+	StoreSettingsCacheTTL       time.Duration       `json:"store_settings_cache_ttl"`
+	MaxCommitRetries            int                 `json:"max_commit_retries"`
+	CommitRetryTimeout          time.Duration       `json:"commit_retry_timeout"`
+	ChangelogPruneWriteConcern  string              `json:"changelog_prune_write_concern"`
+	EmptyChangesAsResult        bool                `json:"empty_changes_as_result"`
+	ForegroundIndexBuilds       bool                `json:"foreground_index_builds"`
+	IndexCreateRetries          int                 `json:"index_create_retries"`
+	StoreSlugs                  bool                `json:"store_slugs"`
+	StorePurgeGracePeriod       time.Duration       `json:"store_purge_grace_period"`
+	StorePurgeInterval          time.Duration       `json:"store_purge_interval"`
+	WriteMode                   string              `json:"write_mode"`
+	MaxConcurrentWritesPerStore int                 `json:"max_concurrent_writes_per_store"`
+	CollectionPrefix            string              `json:"collection_prefix,omitempty"`
+	MaxRetries                  int                 `json:"max_retries"`
+	RetryBaseDelay              time.Duration       `json:"retry_base_delay"`
+	GridFSModelThreshold        int                 `json:"gridfs_model_threshold"`
+	IdentifierNormalization     string              `json:"identifier_normalization"`
+	HardDeleteCascade           bool                `json:"hard_delete_cascade"`
+	IdempotentDeletes           bool                `json:"idempotent_deletes"`
+	QueryTimeout                time.Duration       `json:"query_timeout"`
+	WriteConcern                string              `json:"write_concern"`
+	ReadPreferenceTags          []map[string]string `json:"read_preference_tags,omitempty"`
+	Compressors                 []string            `json:"compressors,omitempty"`
+	ServerSelectionTimeout      time.Duration       `json:"server_selection_timeout"`
+	DeduplicateModels           bool                `json:"deduplicate_models"`
+	MaxConcurrentPerStore       int                 `json:"max_concurrent_per_store"`
+	AllowOutdatedSchema         bool                `json:"allow_outdated_schema"`
+	DefaultPageSize             int                 `json:"default_page_size"`
+	MaxPageSize                 int                 `json:"max_page_size"`
+	ChangelogRetention          time.Duration       `json:"changelog_retention"`
+	StrictStartup               bool                `json:"strict_startup"`
+	AppName                     string              `json:"app_name"`
+	RetryWrites                 bool                `json:"retry_writes"`
+	RetryReads                  bool                `json:"retry_reads"`
+	UniqueStoreNames            bool                `json:"unique_store_names"`
+	MaxStaleness                time.Duration       `json:"max_staleness"`
+	ConditionContextEncoding    string              `json:"condition_context_encoding"`
+	DeleteBatchSize             int                 `json:"delete_batch_size"`
+	UseIndexHints               bool                `json:"use_index_hints"`
+	MaxObjectLength             int                 `json:"max_object_length"`
+	MaxUserLength               int                 `json:"max_user_length"`
+	IdempotencyKeyTTL           time.Duration       `json:"idempotency_key_ttl"`
+	HeartbeatInterval           time.Duration       `json:"heartbeat_interval"`
+}
+
+// EffectiveConfig returns the configuration the datastore is running with. Options left unset
+// are reported with the default the datastore applies in their place; options whose zero value
+// disables a feature (such as MaxConcurrentWritesPerStore) are reported as zero.
+func (ds *Datastore) EffectiveConfig() EffectiveConfig {
+	cfg := ds.config
+
+	readPreference := cfg.ReadPreference
+	if readPreference == "" {
+		readPreference = "primary"
+	}
+
+	maxObjectLength, maxUserLength := ds.tupleLengthLimits()
+
+	conditionContextEncoding := cfg.ConditionContextEncoding
+	if conditionContextEncoding == "" {
+		conditionContextEncoding = ConditionContextBSON
+	}
+
+	connectTimeout := cfg.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = defaultConnectTimeout
+	}
+	serverSelectionTimeout := cfg.ServerSelectionTimeout
+	if serverSelectionTimeout <= 0 {
+		serverSelectionTimeout = defaultServerSelectionTimeout
+	}
+	heartbeatInterval := cfg.HeartbeatInterval
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+
+	maxCommitRetries := ds.maxCommitRetries
+	if maxCommitRetries <= 0 {
+		maxCommitRetries = defaultMaxCommitRetries
+	}
+	commitRetryTimeout := ds.commitRetryTimeout
+	if commitRetryTimeout <= 0 {
+		commitRetryTimeout = defaultCommitRetryTimeout
+	}
+	indexCreateRetries := ds.indexCreateRetries
+	if indexCreateRetries <= 0 {
+		indexCreateRetries = defaultIndexCreateRetries
+	}
+	maxRetries := ds.maxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	retryBaseDelay := ds.retryBaseDelay
+	if retryBaseDelay <= 0 {
+		retryBaseDelay = defaultRetryBaseDelay
+	}
+	storePurgeInterval := ds.storePurgeInterval
+	if storePurgeInterval <= 0 {
+		storePurgeInterval = defaultStorePurgeInterval
+	}
+
+	identifierNormalization := ds.identifierNormalization
+	if identifierNormalization == "" {
+		identifierNormalization = IdentifierNormalizationPreserve
+	}
+
+	changelogPruneWriteConcern := "w:1"
+	if wc := ds.changelogPruneWriteConcern; wc != nil {
+		changelogPruneWriteConcern = describeWriteConcern(wc.W)
+	}
+
+	var connString *connstring.ConnString
+	if cfg.URI != "" {
+		connString, _ = connstring.Parse(cfg.URI)
+	}
+	appName := resolveAppName(&cfg, connString)
+	retryWrites, retryReads := resolveDriverRetries(&cfg, connString)
+
+	effective := EffectiveConfig{
+		URI:                         redactURI(cfg.URI),
+		Database:                    cfg.Database,
+		AuthMechanism:               cfg.AuthMechanism,
+		AuthSource:                  cfg.AuthSource,
+		CAFile:                      cfg.CAFile,
+		ClientCertFile:              cfg.ClientCertFile,
+		MaxOpenConns:                cfg.MaxOpenConns,
+		MinPoolSize:                 cfg.MinPoolSize,
+		ConnMaxIdleTime:             cfg.ConnMaxIdleTime,
+		ConnMaxLifetime:             cfg.ConnMaxLifetime,
+		ConnectTimeout:              connectTimeout,
+		ReadPreference:              readPreference,
+		MaxTuplesPerWrite:           ds.MaxTuplesPerWrite(),
+		MaxTypesPerModel:            ds.MaxTypesPerAuthorizationModel(),
+		ExportMetrics:               cfg.ExportMetrics,
+		RejectEmptyWrites:           ds.rejectEmptyWrites,
+		StrictTupleValidation:       ds.strictTupleValidation,
+		ConditionContextValidation:  ds.conditionContextValidation,
+		StoreSettingsCacheTTL:       ds.storeSettingsCacheTTL,
+		MaxCommitRetries:            maxCommitRetries,
+		CommitRetryTimeout:          commitRetryTimeout,
+		ChangelogPruneWriteConcern:  changelogPruneWriteConcern,
+		EmptyChangesAsResult:        ds.emptyChangesAsResult,
+		ForegroundIndexBuilds:       ds.foregroundIndexBuilds,
+		IndexCreateRetries:          indexCreateRetries,
+		StoreSlugs:                  ds.storeSlugs,
+		StorePurgeGracePeriod:       ds.storePurgeGracePeriod,
+		StorePurgeInterval:          storePurgeInterval,
+		WriteMode:                   ds.activeWriteMode(),
+		MaxConcurrentWritesPerStore: ds.maxConcurrentWritesPerStore,
+		CollectionPrefix:            ds.collectionPrefix,
+		MaxRetries:                  maxRetries,
+		RetryBaseDelay:              retryBaseDelay,
+		GridFSModelThreshold:        ds.gridFSModelThreshold,
+		IdentifierNormalization:     identifierNormalization,
+		HardDeleteCascade:           ds.hardDeleteCascade,
+		IdempotentDeletes:           ds.idempotentDeletes,
+		QueryTimeout:                ds.queryTimeout,
+		WriteConcern:                describeFullWriteConcern(ds.writeConcern),
+		ReadPreferenceTags:          cfg.ReadPreferenceTags,
+		Compressors:                 cfg.Compressors,
+		ServerSelectionTimeout:      serverSelectionTimeout,
+		DeduplicateModels:           ds.deduplicateModels,
+		MaxConcurrentPerStore:       ds.maxConcurrentPerStore,
+		AllowOutdatedSchema:         ds.allowOutdatedSchema,
+		DefaultPageSize:             ds.pageSize(0),
+		MaxPageSize:                 ds.maxPageSizeOrDefault(),
+		ChangelogRetention:          ds.changelogRetention,
+		StrictStartup:               ds.strictStartup,
+		AppName:                     appName,
+		RetryWrites:                 retryWrites,
+		RetryReads:                  retryReads,
+		UniqueStoreNames:            ds.uniqueStoreNames,
+		MaxStaleness:                cfg.MaxStaleness,
+		ConditionContextEncoding:    conditionContextEncoding,
+		DeleteBatchSize:             ds.deleteBatchSize,
+		UseIndexHints:               ds.useIndexHints,
+		MaxObjectLength:             maxObjectLength,
+		MaxUserLength:               maxUserLength,
+		IdempotencyKeyTTL:           ds.idempotencyKeyTTLOrDefault(),
+		HeartbeatInterval:           heartbeatInterval,
+	}
+	if cfg.Username != "" {
+		effective.Username = redacted
+	}
+	if cfg.Password != "" {
+		effective.Password = redacted
+	}
+
+	return effective
+}
+
+// describeWriteConcern formats a write concern's w option the way it is written in a URI.
+func describeWriteConcern(w interface{}) string {
+	switch w := w.(type) {
+	case int:
+		return "w:" + strconv.Itoa(w)
+	case string:
+		return "w:" + w
+	default:
+		return "default"
+	}
+}
+
+// redactURI removes the credentials and query options from a MongoDB connection string,
+// keeping the scheme, hosts and database path. It doesn't use net/url because a seed list
+// ("host1:27017,host2:27017") isn't a valid URL host.
+func redactURI(uri string) string {
+	if uri == "" {
+		return ""
+	}
+
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		return redacted
+	}
+	rest, _, _ = strings.Cut(rest, "?")
+
+	// Everything up to the last "@" is user info, even if an unescaped password contains "/".
+	userInfo := ""
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		userInfo = redacted + "@"
+		rest = rest[at+1:]
+	}
+
+	return scheme + "://" + userInfo + rest
+}
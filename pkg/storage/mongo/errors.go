@@ -0,0 +1,139 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+var (
+	// ErrTupleNotAllowedByModel is returned in strict validation mode when a tuple's
+	// (user type, relation, object type) combination is not a directly related user type in the model.
+	ErrTupleNotAllowedByModel = errors.New("tuple is not allowed by the authorization model")
+
+	// ErrInvalidTuple is returned by Write when a tuple to write has an empty or malformed
+	// object, relation or user. It wraps storage.ErrInvalidWriteInput.
+	ErrInvalidTuple = fmt.Errorf("invalid tuple: %w", storage.ErrInvalidWriteInput)
+
+	// ErrTupleTooLong is returned by Write when a tuple to write has an object longer than
+	// MaxObjectLength or a user longer than MaxUserLength. It wraps ErrInvalidTuple.
+	ErrTupleTooLong = fmt.Errorf("tuple too long: %w", ErrInvalidTuple)
+
+	// ErrConditionContextMismatch is returned when a tuple's condition context doesn't match the
+	// parameters the condition declares in the model.
+	ErrConditionContextMismatch = errors.New("condition context does not match the condition's parameters")
+
+	// ErrStoreExists is returned by CreateStore when the caller-supplied store ID is already taken.
+	// It wraps storage.ErrCollision.
+	ErrStoreExists = fmt.Errorf("store already exists: %w", storage.ErrCollision)
+
+	// ErrModelExists is returned by WriteAuthorizationModel when the store already has a model
+	// with the same id. It wraps storage.ErrCollision.
+	ErrModelExists = fmt.Errorf("authorization model already exists: %w", storage.ErrCollision)
+
+	// ErrModelTooLarge is returned by WriteAuthorizationModel when the model doesn't fit in a
+	// single MongoDB document.
+	ErrModelTooLarge = errors.New("authorization model exceeds the maximum document size")
+
+	// ErrInvalidModelID is returned by ReadAuthorizationModel when the model id is not a ULID, so
+	// no model can have it. It wraps storage.ErrNotFound.
+	ErrInvalidModelID = fmt.Errorf("invalid authorization model id: %w", storage.ErrNotFound)
+
+	// ErrTooManyTypes is returned by WriteAuthorizationModel when the model has more type
+	// definitions than MaxTypesPerAuthorizationModel allows.
+	ErrTooManyTypes = errors.New("authorization model exceeds the maximum number of type definitions")
+
+	// ErrInvalidStoreID is returned by CreateStore when a caller-supplied store ID is malformed.
+	ErrInvalidStoreID = errors.New("invalid store id")
+
+	// ErrTooManyUsers is returned when a read is given more users than it accepts in one call.
+	ErrTooManyUsers = errors.New("too many users in a single read")
+
+	// ErrTooManyObjectRelations is returned by BatchRead when it is given more (object, relation)
+	// pairs than MaxObjectRelationsPerBatchRead.
+	ErrTooManyObjectRelations = errors.New("too many object relations in a single read")
+
+	// ErrTooManyRelations is returned by ReadUserTupleRelations when it is given more relations
+	// than MaxRelationsPerReadUserTupleRelations.
+	ErrTooManyRelations = errors.New("too many relations in a single read")
+
+	// ErrTooManyStores is returned by GetStores when it is given more store ids than
+	// MaxStoresPerGetStores.
+	ErrTooManyStores = errors.New("too many stores in a single read")
+
+	// ErrMembershipGraphDepth is returned by ResolveMembershipGraph when the requested depth is
+	// negative or above MaxMembershipGraphDepth.
+	ErrMembershipGraphDepth = errors.New("membership graph depth out of range")
+
+	// ErrQueryTimeout is returned when the server stops a query that ran longer than QueryTimeout.
+	// It wraps context.DeadlineExceeded, so it is handled like any other deadline.
+	ErrQueryTimeout = fmt.Errorf("mongodb query exceeded the query timeout: %w", context.DeadlineExceeded)
+
+	// ErrWriteConcernNotSatisfied is returned when a write isn't acknowledged at the configured
+	// WriteConcern, such as when its wtimeout passes first. The write may still have been applied
+	// on the primary, and may yet be rolled back.
+	ErrWriteConcernNotSatisfied = errors.New("write concern not satisfied")
+
+	// ErrUnavailable is returned when MongoDB can't be reached: no server could be selected
+	// within ServerSelectionTimeout, or the connection kept failing after retries. It wraps
+	// storage.ErrDatastoreUnavailable, and the driver's error is wrapped with it.
+	ErrUnavailable = fmt.Errorf("mongodb unavailable: %w", storage.ErrDatastoreUnavailable)
+
+	// ErrStoreBusy is returned when a store already has MaxConcurrentPerStore operations in
+	// flight. It wraps storage.ErrTransactionThrottled, which the server reports as
+	// RESOURCE_EXHAUSTED, so callers can back off and retry.
+	ErrStoreBusy = fmt.Errorf("store busy: %w", storage.ErrTransactionThrottled)
+
+	// ErrIdempotencyKeyPending is returned by WriteWithIdempotencyKey in intent mode when an
+	// earlier call with the same key is still applying its batch, so whether it will succeed isn't
+	// known yet. It wraps storage.ErrTransactionThrottled, so the client backs off and retries.
+	ErrIdempotencyKeyPending = fmt.Errorf("idempotent write still in progress: %w", storage.ErrTransactionThrottled)
+
+	// ErrSchemaOutdated is returned by New when the database's documents are at an older schema
+	// version than ExpectedSchemaVersion, and need the migrate command first.
+	ErrSchemaOutdated = errors.New("mongodb schema is outdated, run the migrate command")
+
+	// ErrChangeStreamsUnavailable is returned by Watch when the server has no change streams, as
+	// on a standalone server rather than a replica set or sharded cluster.
+	ErrChangeStreamsUnavailable = errors.New("mongodb change streams are unavailable")
+
+	// ErrInvalidResumeToken is returned by Watch when the server can't resume a change stream
+	// from the given resume token, such as when the token is older than the oplog.
+	ErrInvalidResumeToken = errors.New("change stream can't resume from the resume token")
+
+	// ErrMissingIndexes is returned by ValidateIndexes, and by New with StrictStartup, when
+	// indexes the datastore relies on are missing or differ from the expected ones.
+	ErrMissingIndexes = errors.New("mongodb indexes missing")
+
+	// ErrInvalidStoreName is returned by CreateStore and UpdateStore when the store name is empty or only
+	// whitespace.
+	ErrInvalidStoreName = errors.New("invalid store name")
+
+	// ErrStoreNameTaken is returned by CreateStore and UpdateStore with UniqueStoreNames when another store that
+	// isn't deleted has the same name. It wraps storage.ErrCollision.
+	ErrStoreNameTaken = fmt.Errorf("store name already taken: %w", storage.ErrCollision)
+
+	// ErrPreconditionFailed is returned by ConditionalWrite when the expected tuple isn't stored,
+	// or is stored with another condition. It wraps storage.ErrTransactionalWriteFailed, so callers
+	// read the tuple again and retry as after any other write conflict.
+	ErrPreconditionFailed = fmt.Errorf("conditional write precondition failed: %w", storage.ErrTransactionalWriteFailed)
+
+	// ErrTransactionsUnavailable is returned by ConditionalWrite on a standalone server, which has
+	// no transactions to make the check and the write atomic.
+	ErrTransactionsUnavailable = errors.New("mongodb transactions are unavailable")
+
+	// ErrNoActiveModel is returned by GetActiveModel for a store that never had a model
+	// activated. It wraps storage.ErrNotFound.
+	ErrNoActiveModel = fmt.Errorf("store has no active authorization model: %w", storage.ErrNotFound)
+
+	// ErrClosed is returned by the datastore's methods, and by its open iterators, after Close.
+	ErrClosed = errors.New("mongodb datastore is closed")
+
+	// ErrLocked is returned by AcquireStoreLock when another holder has an unexpired lock on the store.
+	ErrLocked = errors.New("store is locked")
+
+	// ErrLockNotHeld is returned by ReleaseStoreLock when the lock expired or was taken over.
+	ErrLockNotHeld = errors.New("store lock is not held")
+)
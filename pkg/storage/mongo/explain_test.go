@@ -0,0 +1,410 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// queryRecorder records the find and aggregate commands a datastore sends, so that a test can
+// explain exactly the queries a read path runs rather than a copy of them.
+type queryRecorder struct {
+	mu       sync.Mutex
+	commands []bson.Raw
+}
+
+func (r *queryRecorder) monitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, started *event.CommandStartedEvent) {
+			if started.CommandName != "find" && started.CommandName != "aggregate" {
+				return
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			// The driver reuses the command's buffer once it has been sent.
+			r.commands = append(r.commands, append(bson.Raw(nil), started.Command...))
+		},
+	}
+}
+
+// take returns the commands recorded since the last call.
+func (r *queryRecorder) take() []bson.Raw {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	commands := r.commands
+	r.commands = nil
+	return commands
+}
+
+// newRecordingDatastore is like newTestDatastore, but the returned datastore's queries are
+// recorded.
+func newRecordingDatastore(t *testing.T, opts ...ConfigOption) (*Datastore, *queryRecorder) {
+	t.Helper()
+	setup := newTestDatastore(t, opts...)
+
+	recorder := &queryRecorder{}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(setup.config.URI).SetMonitor(recorder.monitor()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Disconnect(ctx)
+	})
+
+	cfg := setup.config
+	datastore, err := NewWithDB(client, client.Database(cfg.Database), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(datastore.Close)
+	recorder.take()
+
+	return datastore, recorder
+}
+
+// explainQuery runs a recorded find or aggregate command under explain and returns the query
+// planner's output.
+func explainQuery(t *testing.T, datastore *Datastore, command bson.Raw) bson.M {
+	t.Helper()
+
+	elements, err := command.Elements()
+	require.NoError(t, err)
+	// Session, cluster time and read settings belong to the original request, not to the query.
+	var query bson.D
+	for _, element := range elements {
+		switch element.Key() {
+		case "find", "aggregate", "filter", "sort", "hint", "limit", "skip", "projection", "pipeline", "cursor":
+			query = append(query, bson.E{Key: element.Key(), Value: element.Value()})
+		}
+	}
+
+	var explain bson.M
+	require.NoError(t, datastore.database.RunCommand(context.Background(), bson.D{
+		{Key: "explain", Value: query},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}).Decode(&explain))
+	return explain
+}
+
+// winningPlans returns every winning plan in an explain output. An aggregation has one per
+// stage that reads the collection.
+func winningPlans(explain interface{}) []interface{} {
+	var plans []interface{}
+	switch value := explain.(type) {
+	case bson.M:
+		for key, nested := range value {
+			if key == "winningPlan" {
+				plans = append(plans, nested)
+				continue
+			}
+			plans = append(plans, winningPlans(nested)...)
+		}
+	case bson.A:
+		for _, nested := range value {
+			plans = append(plans, winningPlans(nested)...)
+		}
+	}
+	return plans
+}
+
+// requireIndexedQueries fails the test unless every recorded query's winning plan reads an index
+// rather than scanning the collection.
+func requireIndexedQueries(t *testing.T, datastore *Datastore, commands []bson.Raw) {
+	t.Helper()
+	require.NotEmpty(t, commands, "no query was recorded")
+
+	for _, command := range commands {
+		plans := winningPlans(explainQuery(t, datastore, command))
+		require.NotEmpty(t, plans, "no winning plan for %s", command)
+		for _, plan := range plans {
+			encoded, err := bson.MarshalExtJSON(bson.M{"plan": plan}, false, false)
+			require.NoError(t, err)
+			require.NotContains(t, string(encoded), "COLLSCAN", "query %s scans the collection: %s", command, encoded)
+		}
+	}
+}
+
+// requireStoreTargeted fails the test unless every recorded query of the tuples and changelog
+// collections matches the store exactly, at the top level of its filter or of its first $match,
+// so that mongos routes it to the single shard holding the store under a store shard key.
+func requireStoreTargeted(t *testing.T, datastore *Datastore, store string, commands []bson.Raw) {
+	t.Helper()
+
+	targeted := map[string]bool{
+		datastore.collectionName(TuplesCollection):    true,
+		datastore.collectionName(ChangelogCollection): true,
+	}
+	for _, command := range commands {
+		var query struct {
+			Find      string   `bson:"find"`
+			Aggregate string   `bson:"aggregate"`
+			Filter    bson.M   `bson:"filter"`
+			Pipeline  []bson.M `bson:"pipeline"`
+		}
+		require.NoError(t, bson.Unmarshal(command, &query))
+
+		filter := query.Filter
+		if query.Aggregate != "" {
+			if !targeted[query.Aggregate] {
+				continue
+			}
+			require.NotEmpty(t, query.Pipeline, "empty pipeline in %s", command)
+			match, ok := query.Pipeline[0]["$match"].(bson.M)
+			require.True(t, ok, "pipeline of %s doesn't start with $match", command)
+			filter = match
+		} else if !targeted[query.Find] {
+			continue
+		}
+		require.Equal(t, store, filter["store"], "query %s doesn't match the store", command)
+	}
+}
+
+func TestQueriesUseIndexes(t *testing.T) {
+	datastore, recorder := newRecordingDatastore(t)
+	ctx := context.Background()
+	store := ulid.Make().String()
+
+	// Other stores' tuples make a collection scan costlier than any index, as in production.
+	for _, s := range []string{store, ulid.Make().String(), ulid.Make().String()} {
+		var writes storage.Writes
+		for i := 0; i < 20; i++ {
+			writes = append(writes,
+				&openfgav1.TupleKey{Object: fmt.Sprintf("document:doc%d", i), Relation: "viewer", User: fmt.Sprintf("user:u%d", i%5)},
+				&openfgav1.TupleKey{Object: fmt.Sprintf("document:doc%d", i), Relation: "editor", User: "group:eng#member"},
+				&openfgav1.TupleKey{Object: fmt.Sprintf("folder:f%d", i), Relation: "viewer", User: "user:*"},
+			)
+		}
+		require.NoError(t, datastore.Write(ctx, s, nil, writes))
+	}
+	require.NoError(t, datastore.Write(ctx, store, storage.Deletes{
+		{Object: "document:doc1", Relation: "viewer", User: "user:u1"},
+	}, nil))
+
+	// drain(t)(it, err) reads the iterator to its end, so that every batch is fetched.
+	drain := func(t *testing.T) func(storage.TupleIterator, error) {
+		return func(it storage.TupleIterator, err error) {
+			require.NoError(t, err)
+			defer it.Stop()
+			for {
+				if _, err := it.Next(ctx); err != nil {
+					require.ErrorIs(t, err, storage.ErrIteratorDone)
+					return
+				}
+			}
+		}
+	}
+
+	cases := map[string]func(t *testing.T){
+		"read_object_relation": func(t *testing.T) {
+			drain(t)(datastore.Read(ctx, store, &openfgav1.TupleKey{Object: "document:doc2", Relation: "viewer"}, storage.ReadOptions{}))
+		},
+		"read_object": func(t *testing.T) {
+			drain(t)(datastore.Read(ctx, store, &openfgav1.TupleKey{Object: "document:doc2"}, storage.ReadOptions{}))
+		},
+		"read_user": func(t *testing.T) {
+			drain(t)(datastore.Read(ctx, store, &openfgav1.TupleKey{User: "user:u2"}, storage.ReadOptions{}))
+		},
+		"read_store": func(t *testing.T) {
+			drain(t)(datastore.Read(ctx, store, nil, storage.ReadOptions{}))
+		},
+		"read_store_empty_key": func(t *testing.T) {
+			drain(t)(datastore.Read(ctx, store, &openfgav1.TupleKey{}, storage.ReadOptions{}))
+		},
+		"read_page_store": func(t *testing.T) {
+			_, _, err := datastore.ReadPage(ctx, store, nil, storage.ReadPageOptions{
+				Pagination: storage.PaginationOptions{PageSize: 5},
+			})
+			require.NoError(t, err)
+		},
+		"read_page": func(t *testing.T) {
+			_, _, err := datastore.ReadPage(ctx, store, &openfgav1.TupleKey{Object: "document:doc2"}, storage.ReadPageOptions{
+				Pagination: storage.PaginationOptions{PageSize: 5},
+			})
+			require.NoError(t, err)
+		},
+		"read_user_tuple": func(t *testing.T) {
+			_, err := datastore.ReadUserTuple(ctx, store, &openfgav1.TupleKey{Object: "document:doc2", Relation: "viewer", User: "user:u2"}, storage.ReadUserTupleOptions{})
+			require.NoError(t, err)
+		},
+		"count_tuples_type": func(t *testing.T) {
+			_, err := datastore.CountTuples(ctx, store, &openfgav1.TupleKey{Object: "document:", Relation: "viewer"})
+			require.NoError(t, err)
+		},
+		"has_tuple": func(t *testing.T) {
+			_, err := datastore.HasTuple(ctx, store, &openfgav1.TupleKey{Object: "document:doc2", Relation: "viewer", User: "user:u2"})
+			require.NoError(t, err)
+		},
+		"read_starting_with_user": func(t *testing.T) {
+			drain(t)(datastore.ReadStartingWithUser(ctx, store, storage.ReadStartingWithUserFilter{
+				ObjectType: "document",
+				Relation:   "viewer",
+				UserFilter: []*openfgav1.ObjectRelation{{Object: "user:u2"}, {Object: "user:*"}},
+			}, storage.ReadStartingWithUserOptions{}))
+		},
+		"read_starting_with_userset": func(t *testing.T) {
+			drain(t)(datastore.ReadStartingWithUser(ctx, store, storage.ReadStartingWithUserFilter{
+				ObjectType: "document",
+				Relation:   "editor",
+				UserFilter: []*openfgav1.ObjectRelation{{Object: "group:eng", Relation: "member"}},
+			}, storage.ReadStartingWithUserOptions{}))
+		},
+		"read_user_tuple_relations": func(t *testing.T) {
+			_, err := datastore.ReadUserTupleRelations(ctx, store, "document:doc2", "user:u2",
+				[]string{"viewer", "editor"}, storage.ReadUserTupleOptions{})
+			require.NoError(t, err)
+		},
+		"read_userset_tuples": func(t *testing.T) {
+			drain(t)(datastore.ReadUsersetTuples(ctx, store, storage.ReadUsersetTuplesFilter{
+				Object:   "document:doc2",
+				Relation: "editor",
+			}, storage.ReadUsersetTuplesOptions{}))
+		},
+		"read_userset_tuples_restricted": func(t *testing.T) {
+			drain(t)(datastore.ReadUsersetTuples(ctx, store, storage.ReadUsersetTuplesFilter{
+				Object:   "folder:f2",
+				Relation: "viewer",
+				AllowedUserTypeRestrictions: []*openfgav1.RelationReference{
+					typesystem.DirectRelationReference("group", "member"),
+					typesystem.WildcardRelationReference("user"),
+				},
+			}, storage.ReadUsersetTuplesOptions{}))
+		},
+		"read_changes": func(t *testing.T) {
+			_, _, err := datastore.ReadChanges(ctx, store, storage.ReadChangesFilter{}, storage.ReadChangesOptions{})
+			require.NoError(t, err)
+		},
+		"read_changes_object_type": func(t *testing.T) {
+			_, _, err := datastore.ReadChanges(ctx, store, storage.ReadChangesFilter{ObjectType: "folder"}, storage.ReadChangesOptions{
+				SortDesc: true,
+			})
+			require.NoError(t, err)
+		},
+		"read_changes_for_operations": func(t *testing.T) {
+			_, _, err := datastore.ReadChangesForOperations(ctx, store, storage.ReadChangesFilter{}, storage.ReadChangesOptions{},
+				openfgav1.TupleOperation_TUPLE_OPERATION_DELETE)
+			require.NoError(t, err)
+		},
+		"batch_read": func(t *testing.T) {
+			_, err := datastore.BatchRead(ctx, store, []*openfgav1.ObjectRelation{
+				{Object: "document:doc2", Relation: "viewer"},
+				{Object: "folder:f2", Relation: "viewer"},
+			}, storage.ReadOptions{})
+			require.NoError(t, err)
+		},
+		"read_filtered_prefix": func(t *testing.T) {
+			drain(t)(datastore.ReadFiltered(ctx, store, ReadFilter{
+				ObjectType:       "document",
+				ObjectIDPrefix:   "doc1",
+				ExcludedRelation: "editor",
+			}, storage.ReadOptions{}))
+		},
+		"read_filtered_user": func(t *testing.T) {
+			drain(t)(datastore.ReadFiltered(ctx, store, ReadFilter{User: "user:u2", ExcludedRelation: "viewer"}, storage.ReadOptions{}))
+		},
+		"read_filtered_direct": func(t *testing.T) {
+			drain(t)(datastore.ReadFiltered(ctx, store, ReadFilter{
+				ObjectType: "document",
+				ObjectID:   "doc1",
+				DirectOnly: true,
+			}, storage.ReadOptions{}))
+		},
+		"list_assertions": func(t *testing.T) {
+			_, _, err := datastore.ListAssertions(ctx, store, storage.PaginationOptions{})
+			require.NoError(t, err)
+		},
+		"read_as_of": func(t *testing.T) {
+			_, err := datastore.ReadAsOf(ctx, store, &openfgav1.TupleKey{Object: "document:doc1"}, time.Now())
+			require.NoError(t, err)
+		},
+	}
+
+	for name, run := range cases {
+		t.Run(name, func(t *testing.T) {
+			recorder.take()
+			run(t)
+			commands := recorder.take()
+			requireIndexedQueries(t, datastore, commands)
+			requireStoreTargeted(t, datastore, store, commands)
+		})
+	}
+}
+
+func TestIndexHints(t *testing.T) {
+	datastore, recorder := newRecordingDatastore(t, WithUseIndexHints(true))
+	ctx := context.Background()
+	store := ulid.Make().String()
+
+	require.NoError(t, datastore.Write(ctx, store, nil, storage.Writes{
+		{Object: "document:doc1", Relation: "viewer", User: "user:anne"},
+		{Object: "document:doc1", Relation: "viewer", User: "group:eng#member"},
+	}))
+	recorder.take()
+
+	drain := func(it storage.TupleIterator, err error) {
+		require.NoError(t, err)
+		defer it.Stop()
+		for {
+			if _, err := it.Next(ctx); err != nil {
+				require.ErrorIs(t, err, storage.ErrIteratorDone)
+				return
+			}
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		read func()
+		hint bson.D
+	}{
+		"read_user_tuple": {func() {
+			_, err := datastore.ReadUserTuple(ctx, store, &openfgav1.TupleKey{Object: "document:doc1", Relation: "viewer", User: "user:anne"}, storage.ReadUserTupleOptions{})
+			require.NoError(t, err)
+		}, tupleIndexKeys},
+		"read_userset_tuples": {func() {
+			drain(datastore.ReadUsersetTuples(ctx, store, storage.ReadUsersetTuplesFilter{
+				Object: "document:doc1", Relation: "viewer",
+			}, storage.ReadUsersetTuplesOptions{}))
+		}, tupleIndexKeys},
+		"read_userset_tuples_object_type": {func() {
+			drain(datastore.ReadUsersetTuples(ctx, store, storage.ReadUsersetTuplesFilter{
+				Object: "document:", Relation: "viewer",
+			}, storage.ReadUsersetTuplesOptions{}))
+		}, objectTypeRelationUserIndexKeys},
+		"read_starting_with_user": {func() {
+			drain(datastore.ReadStartingWithUser(ctx, store, storage.ReadStartingWithUserFilter{
+				ObjectType: "document", Relation: "viewer",
+				UserFilter: []*openfgav1.ObjectRelation{{Object: "user:anne"}},
+			}, storage.ReadStartingWithUserOptions{WithResultsSortedAscending: true}))
+		}, objectTypeRelationUserIndexKeys},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc.read()
+			commands := recorder.take()
+			require.NotEmpty(t, commands)
+			for _, command := range commands {
+				var query struct {
+					Hint bson.D `bson:"hint"`
+				}
+				require.NoError(t, bson.Unmarshal(command, &query))
+				require.Equal(t, tc.hint, query.Hint, "query %s", command)
+			}
+			requireIndexedQueries(t, datastore, commands)
+		})
+	}
+
+	// A missing hinted index fails the startup check.
+	require.NoError(t, datastore.checkHintedIndexes(ctx))
+	_, err := datastore.collection(TuplesCollection).Indexes().DropOne(ctx, "store_1_object_type_1_relation_1_user_1_object_id_1")
+	require.NoError(t, err)
+	require.ErrorIs(t, datastore.checkHintedIndexes(ctx), ErrMissingIndexes)
+}
@@ -0,0 +1,139 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// errWriteReplayed aborts a write whose idempotency key a previous write already recorded.
+var errWriteReplayed = errors.New("idempotency key already used")
+
+// WriteWithIdempotencyKey is like Write, but applies the batch at most once per key and store
+// within IdempotencyKeyTTL, so that a client retrying after an ambiguous timeout doesn't write
+// twice. The key is recorded with the batch, in the same transaction; a later call with the same
+// key succeeds without applying anything, whatever its tuples, as the original call did. A call
+// that failed recorded nothing, so retrying it applies the batch. An empty key is a plain Write.
+//
+// In intent mode, which has no transactions, the key is recorded as pending before the batch is
+// applied, and confirmed once it is applied or removed again if applying it fails. A call with the
+// key of a pending write fails with ErrIdempotencyKeyPending rather than succeed for a write that
+// may still fail.
+func (ds *Datastore) WriteWithIdempotencyKey(
+	ctx context.Context,
+	store string,
+	key string,
+	deletes storage.Deletes,
+	writes storage.Writes,
+) (err error) {
+	ctx, span := ds.startTrace(ctx, "WriteWithIdempotencyKey", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	return ds.write(ctx, store, key, deletes, writes, nil)
+}
+
+// idempotencyKeyUsed reports whether a write recorded the store's key and it hasn't expired yet.
+// It returns ErrIdempotencyKeyPending if the write that recorded it is still being applied.
+func (ds *Datastore) idempotencyKeyUsed(ctx context.Context, store, key string) (bool, error) {
+	collection := ds.collection(IdempotencyKeysCollection)
+	filter := bson.M{
+		"store":      store,
+		"key":        key,
+		"expires_at": bson.M{"$gt": primitive.NewDateTimeFromTime(time.Now())},
+	}
+	opts := ds.findOneTimeout().SetProjection(bson.M{"_id": 0, "pending": 1})
+	var doc struct {
+		Pending bool `bson:"pending,omitempty"`
+	}
+	err := ds.retry(ctx, func() error {
+		return collection.FindOne(ctx, filter, opts).Decode(&doc)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("find idempotency key: %w", err)
+	}
+	if doc.Pending {
+		return false, ErrIdempotencyKeyPending
+	}
+	return true, nil
+}
+
+// recordIdempotencyKey records the store's key for IdempotencyKeyTTL, and returns the token that
+// identifies the record. A pending record stands for a write that is still being applied, until
+// confirmIdempotencyKey or forgetIdempotencyKey. It returns errWriteReplayed if a write already
+// recorded the key and it hasn't expired.
+func (ds *Datastore) recordIdempotencyKey(ctx context.Context, store, key string, pending bool) (string, error) {
+	now := time.Now()
+	token := ulid.Make().String()
+
+	// An expired key is taken over in place, as the TTL monitor may not have removed it yet; a
+	// live one makes the upsert insert a second document for it, which the unique index rejects.
+	filter := bson.M{
+		"store":      store,
+		"key":        key,
+		"expires_at": bson.M{"$lte": primitive.NewDateTimeFromTime(now)},
+	}
+	update := bson.M{"$set": bson.M{
+		"token":      token,
+		"pending":    pending,
+		"expires_at": primitive.NewDateTimeFromTime(now.Add(ds.idempotencyKeyTTLOrDefault())),
+	}}
+
+	_, err := ds.writeCollection(IdempotencyKeysCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return "", errWriteReplayed
+	}
+	if err != nil {
+		return "", fmt.Errorf("record idempotency key: %w", err)
+	}
+	return token, nil
+}
+
+// confirmIdempotencyKey marks the pending record recordIdempotencyKey returned the token of as
+// the key of an applied write, so that retries with the key succeed. A record that can't be
+// confirmed stays pending until it expires, and retries fail with ErrIdempotencyKeyPending meanwhile.
+func (ds *Datastore) confirmIdempotencyKey(ctx context.Context, store, key, token string) {
+	ctx = context.WithoutCancel(ctx)
+	collection := ds.writeCollection(IdempotencyKeysCollection)
+	err := ds.retry(ctx, func() error {
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"store": store, "key": key, "token": token},
+			bson.M{"$set": bson.M{"pending": false}})
+		return err
+	})
+	if err != nil {
+		ds.logger.Warn("failed to confirm idempotency key of an applied write",
+			zap.String("store", store), zap.String("key", key), zap.Error(err))
+	}
+}
+
+// forgetIdempotencyKey removes the record recordIdempotencyKey returned the token of, so that a
+// failed intent mode write can be retried. The record expires anyway if it can't be removed.
+func (ds *Datastore) forgetIdempotencyKey(ctx context.Context, store, key, token string) {
+	_, err := ds.writeCollection(IdempotencyKeysCollection).DeleteOne(context.WithoutCancel(ctx),
+		bson.M{"store": store, "key": key, "token": token})
+	if err != nil {
+		ds.logger.Warn("failed to remove idempotency key of a failed write",
+			zap.String("store", store), zap.String("key", key), zap.Error(err))
+	}
+}
+
+// idempotencyKeyTTLOrDefault returns IdempotencyKeyTTL, or its default when unset.
+func (ds *Datastore) idempotencyKeyTTLOrDefault() time.Duration {
+	if ds.idempotencyKeyTTL <= 0 {
+		return DefaultIdempotencyKeyTTL
+	}
+	return ds.idempotencyKeyTTL
+}
@@ -0,0 +1,299 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// DefaultImportBatchSize is the number of tuples ImportTuples inserts per batch when
+// ImportOptions.BatchSize is zero.
+const DefaultImportBatchSize = 1000
+
+// ImportOptions configures ImportTuples.
+type ImportOptions struct {
+	// BatchSize is the number of tuples inserted per bulk write. Zero or less means
+	// DefaultImportBatchSize.
+	BatchSize int
+	// ResumeAfter is the Checkpoint of an earlier, interrupted import of the same tuples. Tuples
+	// up to and including it are skipped without being written.
+	ResumeAfter string
+	// OnBatch, when set, is called after every batch with its stats. Returning an error stops the
+	// import with that error; the batch it was called for is already written.
+	OnBatch func(ImportBatch) error
+}
+
+// ImportBatch reports the outcome of one batch of ImportTuples.
+type ImportBatch struct {
+	Inserted          int `json:"inserted"`
+	SkippedDuplicates int `json:"skipped_duplicates"`
+	Failed            int `json:"failed"`
+	// Failures are the batch's tuples that were neither inserted nor duplicates, with the reason.
+	Failures []ImportFailure `json:"-"`
+	// Checkpoint identifies the last tuple of the batch, to be passed as ImportOptions.ResumeAfter.
+	Checkpoint string `json:"checkpoint"`
+}
+
+// ImportFailure is a tuple ImportTuples couldn't write, either because it is malformed or not
+// allowed by the store's model, or because the server rejected it.
+type ImportFailure struct {
+	TupleKey *openfgav1.TupleKey
+	Err      error
+}
+
+// ImportResult totals the batches of ImportTuples.
+type ImportResult struct {
+	Inserted          int64 `json:"inserted"`
+	SkippedDuplicates int64 `json:"skipped_duplicates"`
+	Failed            int64 `json:"failed"`
+	Batches           int   `json:"batches"`
+	// Checkpoint is the checkpoint of the last batch written, empty when none was.
+	Checkpoint string `json:"checkpoint"`
+}
+
+// ImportTuples writes every tuple of the iterator to the store, for bulk loads such as a
+// migration from another FGA deployment. Unlike Write it favors throughput over atomicity: tuples
+// are inserted in unordered bulk writes of BatchSize outside any transaction, and a tuple that
+// already exists, or that the import repeats, is counted as a skipped duplicate instead of failing
+// the batch. Tuples are validated as Write validates them, but a malformed tuple, or one the
+// store's model doesn't allow under strict validation, is counted as failed and the import goes
+// on. Each inserted tuple gets a changelog entry.
+//
+// Any other error, from the iterator or the server, stops the import. The returned result then
+// covers the batches written so far, and its Checkpoint resumes the import after the last of
+// them when passed as ImportOptions.ResumeAfter with an iterator yielding the same tuples in the
+// same order. Tuples of the interrupted batch that did get written are skipped as duplicates on
+// resume, but an import stopped between a batch's tuples and its changelog entries leaves those
+// tuples without changelog entries. The iterator is stopped before ImportTuples returns.
+func (ds *Datastore) ImportTuples(
+	ctx context.Context,
+	store string,
+	tuples storage.TupleKeyIterator,
+	opts ImportOptions,
+) (_ *ImportResult, err error) {
+	ctx, span := ds.startTrace(ctx, "ImportTuples", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	return ds.importTuples(ctx, store, tuples, opts, nil)
+}
+
+// importTuples implements ImportTuples and ImportTuplesNDJSON. Each imported tuple expires at
+// expiry(tuple), when expiry is set and returns a time.
+func (ds *Datastore) importTuples(
+	ctx context.Context,
+	store string,
+	tuples storage.TupleKeyIterator,
+	opts ImportOptions,
+	expiry func(*openfgav1.TupleKey) *primitive.DateTime,
+) (*ImportResult, error) {
+	defer tuples.Stop()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+	if store == "" {
+		return nil, fmt.Errorf("store id is required: %w", storage.ErrInvalidWriteInput)
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+
+	validate, err := ds.importValidator(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	resuming := opts.ResumeAfter != ""
+	batch := make([]*openfgav1.TupleKey, 0, batchSize)
+	for done := false; !done; {
+		tk, err := tuples.Next(ctx)
+		switch {
+		case errors.Is(err, storage.ErrIteratorDone):
+			done = true
+		case err != nil:
+			return result, fmt.Errorf("read tuple to import: %w", err)
+		case resuming:
+			resuming = tupleUtils.TupleKeyToString(tk) != opts.ResumeAfter
+			continue
+		default:
+			batch = append(batch, tk)
+		}
+
+		if len(batch) == batchSize || (done && len(batch) > 0) {
+			stats, err := ds.importBatch(ctx, store, batch, validate, expiry)
+			if err != nil {
+				return result, fmt.Errorf("import batch %d: %w", result.Batches+1, err)
+			}
+			result.Inserted += int64(stats.Inserted)
+			result.SkippedDuplicates += int64(stats.SkippedDuplicates)
+			result.Failed += int64(stats.Failed)
+			result.Batches++
+			result.Checkpoint = stats.Checkpoint
+			batch = batch[:0]
+
+			if opts.OnBatch != nil {
+				if err := opts.OnBatch(stats); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+
+	// Resuming from a checkpoint the iterator never yielded would otherwise import nothing and
+	// report success.
+	if resuming {
+		return result, fmt.Errorf("resume checkpoint '%s' not found: %w", opts.ResumeAfter, storage.ErrInvalidWriteInput)
+	}
+
+	return result, nil
+}
+
+// importValidator returns the check ImportTuples applies to each tuple: the shape checks of
+// every Write, plus the store's model when strict tuple validation or condition context
+// validation applies. The model is read at most once, so it must not change during the import.
+func (ds *Datastore) importValidator(ctx context.Context, store string) (func(*openfgav1.TupleKey) error, error) {
+	strict, err := ds.strictTupleValidationFor(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	var model *openfgav1.AuthorizationModel
+	loadModel := func() error {
+		if model != nil {
+			return nil
+		}
+		if model, err = ds.FindLatestAuthorizationModel(ctx, store); err != nil {
+			return fmt.Errorf("tuple validation: %w", err)
+		}
+		return nil
+	}
+	// Like Write, strict validation fails outright when the store has no model.
+	if strict {
+		if err := loadModel(); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(tk *openfgav1.TupleKey) error {
+		writes := storage.Writes{tk}
+		if err := validateTupleKeys(writes); err != nil {
+			return err
+		}
+		if err := ds.validateTupleLengths(writes); err != nil {
+			return err
+		}
+		if strict {
+			if err := ds.ValidateWritesAgainstModel(model, writes); err != nil {
+				return err
+			}
+		}
+		if ds.conditionContextValidation && hasConditionContext(writes) {
+			if err := loadModel(); err != nil {
+				return err
+			}
+			return ds.ValidateConditionContexts(model, writes)
+		}
+		return nil
+	}, nil
+}
+
+// importBatch inserts one batch of ImportTuples and the changelog entries of the tuples it
+// inserted. A tuple whose expiry has already passed fails.
+func (ds *Datastore) importBatch(
+	ctx context.Context,
+	store string,
+	batch []*openfgav1.TupleKey,
+	validate func(*openfgav1.TupleKey) error,
+	expiry func(*openfgav1.TupleKey) *primitive.DateTime,
+) (ImportBatch, error) {
+	stats := ImportBatch{Checkpoint: tupleUtils.TupleKeyToString(batch[len(batch)-1])}
+
+	release, err := ds.acquireWriteSlot(ctx, store)
+	if err != nil {
+		return stats, fmt.Errorf("wait for write slot: %w", err)
+	}
+	defer release()
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	keys := make([]*openfgav1.TupleKey, 0, len(batch))
+	docs := make([]interface{}, 0, len(batch))
+	for _, tk := range batch {
+		var expiresAt *primitive.DateTime
+		if expiry != nil {
+			expiresAt = expiry(tk)
+		}
+		tk = ds.normalizeTupleKey(tk)
+		if err := validate(tk); err != nil {
+			stats.Failures = append(stats.Failures, ImportFailure{TupleKey: tk, Err: err})
+			continue
+		}
+		if expiresAt != nil && expiresAt.Time().Before(now.Time()) {
+			stats.Failures = append(stats.Failures, ImportFailure{TupleKey: tk, Err: fmt.Errorf("tuple expired at %s: %w",
+				expiresAt.Time().UTC().Format(time.RFC3339), storage.ErrInvalidWriteInput)})
+			continue
+		}
+		doc, err := tupleKeyToDoc(store, tk)
+		if err != nil {
+			stats.Failures = append(stats.Failures, ImportFailure{TupleKey: tk, Err: err})
+			continue
+		}
+		doc.ExpiresAt = expiresAt
+		keys = append(keys, tk)
+		docs = append(docs, doc)
+	}
+
+	// The indexes of the documents the server rejected; the others were inserted.
+	rejected := map[int]bool{}
+	if len(docs) > 0 {
+		_, err := ds.writeCollection(TuplesCollection).InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+		var bulkErr mongo.BulkWriteException
+		switch {
+		case err == nil:
+		case errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil && len(bulkErr.WriteErrors) > 0:
+			for _, writeErr := range bulkErr.WriteErrors {
+				if writeErr.Index < 0 || writeErr.Index >= len(keys) {
+					return stats, fmt.Errorf("insert tuples: %w", err)
+				}
+				rejected[writeErr.Index] = true
+				if mongo.IsDuplicateKeyError(writeErr) {
+					stats.SkippedDuplicates++
+					continue
+				}
+				stats.Failures = append(stats.Failures, ImportFailure{TupleKey: keys[writeErr.Index], Err: writeErr})
+			}
+		default:
+			return stats, fmt.Errorf("insert tuples: %w", unavailableError(writeConcernError(err)))
+		}
+	}
+	stats.Failed = len(stats.Failures)
+
+	changes := make([]interface{}, 0, len(docs)-len(rejected))
+	for i, doc := range docs {
+		if rejected[i] {
+			continue
+		}
+		changes = append(changes, docToChange(doc.(*TupleDocument), openfgav1.TupleOperation_TUPLE_OPERATION_WRITE, now))
+	}
+	stats.Inserted = len(changes)
+
+	if len(changes) > 0 {
+		if _, err := ds.writeCollection(ChangelogCollection).InsertMany(ctx, changes); err != nil {
+			return stats, fmt.Errorf("insert changelog entries: %w", unavailableError(writeConcernError(err)))
+		}
+	}
+
+	return stats, nil
+}
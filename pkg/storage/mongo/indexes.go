@@ -0,0 +1,617 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// indexSpec describes an index the datastore relies on.
+type indexSpec struct {
+	// description names the index in logs and errors.
+	description string
+	collection  string
+	model       mongo.IndexModel
+}
+
+// tupleIndexKeys are the keys of the tuples collection's unique index, which leads with the object.
+// The condition is deliberately not part of the key: a tuple can exist only once, whatever its
+// condition. The user field holds usersets in full ("group:eng#member"), so a userset never
+// collides with a plain user of the same object.
+var tupleIndexKeys = bson.D{
+	{Key: "store", Value: 1},
+	{Key: "object_type", Value: 1},
+	{Key: "object_id", Value: 1},
+	{Key: "relation", Value: 1},
+	{Key: "user", Value: 1},
+}
+
+// userIndexKeys are the keys of the index serving reads of every tuple of a user, whatever the
+// object. ulid last keeps ReadPage's ULID order and continuation off an in-memory sort.
+var userIndexKeys = bson.D{
+	{Key: "store", Value: 1},
+	{Key: "user", Value: 1},
+	{Key: "ulid", Value: 1},
+}
+
+// objectTypeRelationUserIndexKeys are the keys of the index serving ReadStartingWithUser and
+// ListObjects, which match an object type and relation across objects.
+var objectTypeRelationUserIndexKeys = bson.D{
+	{Key: "store", Value: 1},
+	{Key: "object_type", Value: 1},
+	{Key: "relation", Value: 1},
+	{Key: "user", Value: 1},
+	{Key: "object_id", Value: 1},
+}
+
+// hintedIndexKeys are the indexes UseIndexHints forces on the hot read paths.
+var hintedIndexKeys = []bson.D{tupleIndexKeys, objectTypeRelationUserIndexKeys}
+
+// authorizationModelIndexKeys are the keys of the authorization models index. Model ids are
+// ULIDs, so the index also orders each store's models by creation time.
+var authorizationModelIndexKeys = bson.D{
+	{Key: "store", Value: 1},
+	{Key: "id", Value: 1},
+}
+
+// assertionIndexKeys are the keys of the assertions index, which serves both the lookup of a
+// model's assertion set and ListAssertions' walk of a store's sets in model order.
+var assertionIndexKeys = bson.D{
+	{Key: "store", Value: 1},
+	{Key: "model_id", Value: 1},
+}
+
+// indexSpecs returns every index the datastore needs, in the order they are built.
+func indexSpecs() []indexSpec {
+	return []indexSpec{
+		{
+			// Compound index for tuple lookups
+			description: "tuple",
+			collection:  TuplesCollection,
+			model: mongo.IndexModel{
+				Keys:    tupleIndexKeys,
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		{
+			// ReadStartingWithUser and ListObjects match a store, object type and relation, and
+			// one or more users. object_id last serves their object id filters and sorted reads.
+			description: "object type relation user",
+			collection:  TuplesCollection,
+			model: mongo.IndexModel{
+				Keys: objectTypeRelationUserIndexKeys,
+			},
+		},
+		{
+			// Index for reverse lookups (ReadStartingWithUser)
+			description: "reverse tuple",
+			collection:  TuplesCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "store", Value: 1},
+					{Key: "user", Value: 1},
+					{Key: "object_type", Value: 1},
+					{Key: "relation", Value: 1},
+				},
+			},
+		},
+		{
+			// Index for reads of a user's tuples across all objects (Read and ReadPage with only
+			// a user)
+			description: "user",
+			collection:  TuplesCollection,
+			model: mongo.IndexModel{
+				Keys: userIndexKeys,
+			},
+		},
+		{
+			// Index for incremental reads of an object (ReadTuplesModifiedSince)
+			description: "object modification time",
+			collection:  TuplesCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "store", Value: 1},
+					{Key: "object_type", Value: 1},
+					{Key: "object_id", Value: 1},
+					{Key: "inserted_at", Value: 1},
+					{Key: "ulid", Value: 1},
+				},
+			},
+		},
+		{
+			// Index for condition audits (ReadTuplesByCondition); tuples without a condition are
+			// indexed under a null name.
+			description: "tuple condition",
+			collection:  TuplesCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "store", Value: 1},
+					{Key: "condition.name", Value: 1},
+					{Key: "ulid", Value: 1},
+				},
+			},
+		},
+		{
+			// Index for following userset edges (ResolveMembershipGraph)
+			description: "object relation",
+			collection:  TuplesCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "store", Value: 1},
+					{Key: "object_relation", Value: 1},
+				},
+			},
+		},
+		{
+			// Serves ReadUsersetTuples with type restrictions. Only usersets and wildcards have
+			// a user_type, so plain users aren't indexed.
+			description: "userset type",
+			collection:  TuplesCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "store", Value: 1},
+					{Key: "object_type", Value: 1},
+					{Key: "relation", Value: 1},
+					{Key: "user_type", Value: 1},
+					{Key: "object_id", Value: 1},
+				},
+				Options: options.Index().SetPartialFilterExpression(bson.M{"user_type": bson.M{"$exists": true}}),
+			},
+		},
+		{
+			// Deletes tuples written with WriteWithExpiry once they expire. Only documents with
+			// a date in expires_at are indexed, so other tuples never expire.
+			description: "tuple expiry",
+			collection:  TuplesCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().
+					SetExpireAfterSeconds(0).
+					SetPartialFilterExpression(bson.M{"expires_at": bson.M{"$type": "date"}}),
+			},
+		},
+		{
+			description: "authorization model",
+			collection:  AuthorizationModelsCollection,
+			model: mongo.IndexModel{
+				Keys:    authorizationModelIndexKeys,
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		{
+			description: "assertions",
+			collection:  AssertionsCollection,
+			model:       mongo.IndexModel{Keys: assertionIndexKeys},
+		},
+		{
+			description: "store",
+			collection:  StoresCollection,
+			model: mongo.IndexModel{
+				Keys:    bson.D{{Key: "id", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		{
+			// Only stores created with a slug are indexed, so slugs stay optional.
+			description: "store slug",
+			collection:  StoresCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{{Key: "slug", Value: 1}},
+				Options: options.Index().
+					SetName(storeSlugIndexName).
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"slug": bson.M{"$type": "string"}}),
+			},
+		},
+		{
+			// Only stores created with UniqueStoreNames, and not deleted since, are indexed.
+			description: "store unique name",
+			collection:  StoresCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{{Key: "unique_name", Value: 1}},
+				Options: options.Index().
+					SetName(storeUniqueNameIndexName).
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"unique_name": bson.M{"$type": "string"}}),
+			},
+		},
+		{
+			description: "changelog",
+			collection:  ChangelogCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "store", Value: 1},
+					{Key: "ulid", Value: 1},
+				},
+			},
+		},
+		{
+			// Serves ReadChangesForOperations in ULID order, like the changelog index.
+			description: "changelog operation",
+			collection:  ChangelogCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "store", Value: 1},
+					{Key: "operation", Value: 1},
+					{Key: "ulid", Value: 1},
+				},
+			},
+		},
+		{
+			// Serves ReadAsOf, which replays one object's changes in ULID order.
+			description: "changelog object",
+			collection:  ChangelogCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "store", Value: 1},
+					{Key: "object_type", Value: 1},
+					{Key: "object_id", Value: 1},
+					{Key: "relation", Value: 1},
+					{Key: "ulid", Value: 1},
+				},
+			},
+		},
+		{
+			description: "store settings",
+			collection:  StoreSettingsCollection,
+			model: mongo.IndexModel{
+				Keys:    bson.D{{Key: "store", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		{
+			description: "store lock",
+			collection:  LocksCollection,
+			model: mongo.IndexModel{
+				Keys:    bson.D{{Key: "store", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		{
+			// Lets the server remove expired locks; acquisition also treats them as free.
+			description: "store lock expiry",
+			collection:  LocksCollection,
+			model: mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+		{
+			description: "idempotency key",
+			collection:  IdempotencyKeysCollection,
+			model: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "store", Value: 1},
+					{Key: "key", Value: 1},
+				},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		{
+			// Lets the server remove expired idempotency keys; writes also treat them as unused.
+			description: "idempotency key expiry",
+			collection:  IdempotencyKeysCollection,
+			model: mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+	}
+}
+
+// Server error codes returned when creating an index that clashes with an existing or concurrent one.
+const (
+	mongoIndexOptionsConflictCode  = 85
+	mongoIndexKeySpecsConflictCode = 86
+	mongoIndexBuildAbortedCode     = 276
+	defaultIndexCreateRetries      = 5
+)
+
+// EnsureIndexes creates the indexes the datastore relies on. Indexes are built one at a time,
+// logging progress, so that a large existing collection only has one build running against it at
+// any moment. It is safe to call from many instances at once: an index that already exists with
+// the same keys and options counts as created, and builds interrupted by a concurrent build are
+// retried up to IndexCreateRetries times. Only an existing index whose name matches but whose
+// keys or options differ is an error.
+func (ds *Datastore) EnsureIndexes(ctx context.Context) (err error) {
+	ctx, span := ds.startTrace(ctx, "EnsureIndexes")
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return err
+	}
+
+	return ds.ensureIndexes(ctx, nil)
+}
+
+// ValidateIndexes checks, without building anything, that every index EnsureIndexes would build
+// exists with the expected keys and options. It fails with ErrMissingIndexes naming each index
+// that is missing or differs, for deployments that build indexes with the migrate command only.
+func (ds *Datastore) ValidateIndexes(ctx context.Context) (err error) {
+	ctx, span := ds.startTrace(ctx, "ValidateIndexes")
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, spec := range indexSpecs() {
+		model := spec.model
+		if model.Options == nil {
+			model.Options = options.Index()
+		}
+		same, found, err := hasMatchingIndex(ctx, ds.collection(spec.collection).Indexes(), model)
+		if err != nil {
+			return fmt.Errorf("look up %s index: %w", spec.description, err)
+		}
+		switch {
+		case !found:
+			missing = append(missing, fmt.Sprintf("%s (%s)", spec.description, ds.collectionName(spec.collection)))
+		case !same:
+			missing = append(missing, fmt.Sprintf("%s (%s, with different keys or options)", spec.description, ds.collectionName(spec.collection)))
+		}
+	}
+
+	if ds.changelogRetention > 0 {
+		expiry, err := ds.changelogRetentionExpiry(ctx)
+		if err != nil {
+			return err
+		}
+		if expiry == nil || *expiry != int32(ds.changelogRetention/time.Second) {
+			missing = append(missing, fmt.Sprintf("changelog retention (%s)", ds.collectionName(ChangelogCollection)))
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s; run the migrate command to build them", ErrMissingIndexes, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkHintedIndexes fails with ErrMissingIndexes when an index that UseIndexHints forces doesn't
+// exist, since every read hinting it would fail. It only looks the indexes up by name.
+func (ds *Datastore) checkHintedIndexes(ctx context.Context) error {
+	indexes := ds.collection(TuplesCollection).Indexes()
+	var missing []string
+	for _, keys := range hintedIndexKeys {
+		_, found, err := hasMatchingIndex(ctx, indexes, mongo.IndexModel{Keys: keys, Options: options.Index()})
+		if err != nil {
+			return fmt.Errorf("look up hinted index: %w", err)
+		}
+		if !found {
+			raw, err := bson.Marshal(keys)
+			if err != nil {
+				return fmt.Errorf("marshal index keys: %w", err)
+			}
+			missing = append(missing, indexName(raw))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s (%s), hinted with UseIndexHints; run the migrate command to build them",
+			ErrMissingIndexes, strings.Join(missing, ", "), ds.collectionName(TuplesCollection))
+	}
+	return nil
+}
+
+// ensureIndexes builds every index in indexSpecs, then the changelog retention index. When report
+// is not nil, each index is recorded in it as created or as already present.
+func (ds *Datastore) ensureIndexes(ctx context.Context, report *MigrationReport) error {
+	specs := indexSpecs()
+	for i, spec := range specs {
+		opts := spec.model.Options
+		if opts == nil {
+			opts = options.Index()
+		}
+		// Only honored by servers older than 4.2; newer ones always use a hybrid build that
+		// holds an exclusive lock only at the start and end of the build.
+		opts.SetBackground(!ds.foregroundIndexBuilds)
+
+		ds.logger.Info("building mongodb index",
+			zap.String("index", spec.description),
+			zap.String("collection", spec.collection),
+			zap.Int("step", i+1),
+			zap.Int("total", len(specs)),
+		)
+
+		model := mongo.IndexModel{Keys: spec.model.Keys, Options: opts}
+		existed := false
+		if report != nil {
+			var err error
+			_, existed, err = hasMatchingIndex(ctx, ds.collection(spec.collection).Indexes(), model)
+			if err != nil {
+				return fmt.Errorf("look up %s index: %w", spec.description, err)
+			}
+		}
+
+		start := time.Now()
+		if err := ds.ensureIndex(ctx, spec.collection, model); err != nil {
+			return fmt.Errorf("create %s index: %w", spec.description, err)
+		}
+
+		if report != nil {
+			if existed {
+				report.ExistingIndexes = append(report.ExistingIndexes, spec.description)
+			} else {
+				report.CreatedIndexes = append(report.CreatedIndexes, spec.description)
+			}
+		}
+
+		ds.logger.Info("built mongodb index",
+			zap.String("index", spec.description),
+			zap.Duration("duration", time.Since(start)),
+		)
+	}
+
+	existed, err := ds.ensureChangelogRetention(ctx)
+	if err != nil {
+		return err
+	}
+	if report != nil && ds.changelogRetention > 0 {
+		if existed {
+			report.ExistingIndexes = append(report.ExistingIndexes, "changelog retention")
+		} else {
+			report.CreatedIndexes = append(report.CreatedIndexes, "changelog retention")
+		}
+	}
+
+	return nil
+}
+
+// ensureIndex creates a single index, retrying builds that fail because of a concurrent build.
+func (ds *Datastore) ensureIndex(ctx context.Context, collection string, model mongo.IndexModel) error {
+	retries := ds.indexCreateRetries
+	if retries <= 0 {
+		retries = defaultIndexCreateRetries
+	}
+
+	policy := backoff.WithContext(backoff.WithMaxRetries(backoff.NewExponentialBackOff(), uint64(retries)), ctx)
+	return backoff.Retry(func() error {
+		indexes := ds.collection(collection).Indexes()
+		_, err := indexes.CreateOne(ctx, model)
+		if err == nil {
+			return nil
+		}
+
+		var cmdErr mongo.CommandError
+		if !errors.As(err, &cmdErr) {
+			return backoff.Permanent(err)
+		}
+
+		switch cmdErr.Code {
+		case mongoIndexOptionsConflictCode, mongoIndexKeySpecsConflictCode:
+			same, found, lookupErr := hasMatchingIndex(ctx, indexes, model)
+			if lookupErr != nil {
+				return backoff.Permanent(lookupErr)
+			}
+			if same {
+				return nil
+			}
+			if found {
+				return backoff.Permanent(err)
+			}
+			// The conflicting index was dropped in the meantime.
+			return err
+		case mongoIndexBuildAbortedCode:
+			return err
+		default:
+			return backoff.Permanent(err)
+		}
+	}, policy)
+}
+
+// hasMatchingIndex reports whether the collection has an index named like model (found) and
+// whether its keys, uniqueness and expiry also match model (same).
+func hasMatchingIndex(ctx context.Context, indexes mongo.IndexView, model mongo.IndexModel) (same, found bool, err error) {
+	keys, err := bson.Marshal(model.Keys)
+	if err != nil {
+		return false, false, fmt.Errorf("marshal index keys: %w", err)
+	}
+
+	opts := model.Options
+	name := indexName(keys)
+	if opts.Name != nil {
+		name = *opts.Name
+	}
+
+	cursor, err := indexes.List(ctx)
+	if err != nil {
+		return false, false, fmt.Errorf("list indexes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var existing struct {
+			Name               string   `bson:"name"`
+			Key                bson.Raw `bson:"key"`
+			Unique             bool     `bson:"unique"`
+			ExpireAfterSeconds *int32   `bson:"expireAfterSeconds"`
+		}
+		if err := cursor.Decode(&existing); err != nil {
+			return false, false, fmt.Errorf("decode index: %w", err)
+		}
+		if existing.Name != name {
+			continue
+		}
+
+		wantUnique := opts.Unique != nil && *opts.Unique
+		sameExpiry := (opts.ExpireAfterSeconds == nil) == (existing.ExpireAfterSeconds == nil) &&
+			(opts.ExpireAfterSeconds == nil || *opts.ExpireAfterSeconds == *existing.ExpireAfterSeconds)
+
+		same := sameIndexKeys(keys, existing.Key) && wantUnique == existing.Unique && sameExpiry
+		return same, true, nil
+	}
+
+	return false, false, cursor.Err()
+}
+
+// sameIndexKeys compares index key documents field by field, treating numeric directions of
+// different BSON types (1 and 1.0) as equal.
+func sameIndexKeys(a, b bson.Raw) bool {
+	aElems, errA := a.Elements()
+	bElems, errB := b.Elements()
+	if errA != nil || errB != nil || len(aElems) != len(bElems) {
+		return false
+	}
+
+	for i := range aElems {
+		if aElems[i].Key() != bElems[i].Key() {
+			return false
+		}
+		aValue, bValue := aElems[i].Value(), bElems[i].Value()
+		aNum, aIsNum := aValue.AsInt64OK()
+		bNum, bIsNum := bValue.AsInt64OK()
+		if aIsNum && bIsNum {
+			if aNum != bNum {
+				return false
+			}
+		} else if !aValue.Equal(bValue) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// indexName returns the name the server generates for an index with the given keys.
+func indexName(keys bson.Raw) string {
+	elems, _ := keys.Elements()
+	parts := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		value := elem.Value()
+		if direction, ok := value.AsInt64OK(); ok {
+			parts = append(parts, fmt.Sprintf("%s_%d", elem.Key(), direction))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s_%s", elem.Key(), value.StringValue()))
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// hintTupleIndex makes reads of a whole object (an object without a relation) use the
+// object-leading tuple index. When the read also names a user, the planner could otherwise pick
+// the user-leading reverse index and scan every tuple of that user in the store. Reads of a
+// user without an object use the user index, which also serves their ULID order. Reads of a
+// whole object type are left to the planner, which has the reverse index for a user.
+func hintTupleIndex(opts *options.FindOptions, tupleKey *openfgav1.TupleKey) *options.FindOptions {
+	_, objectID := tupleUtils.SplitObject(tupleKey.GetObject())
+	switch {
+	case objectID != "" && tupleKey.GetRelation() == "":
+		opts.SetHint(tupleIndexKeys)
+	case tupleKey.GetObject() == "" && tupleKey.GetUser() != "":
+		opts.SetHint(userIndexKeys)
+	}
+	return opts
+}
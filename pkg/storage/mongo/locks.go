@@ -0,0 +1,85 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StoreLock is a held lock on a store, returned by AcquireStoreLock.
+type StoreLock struct {
+	Store string
+	// Token identifies this holder; only the holder with the token can release the lock.
+	Token     string
+	ExpiresAt time.Time
+}
+
+// AcquireStoreLock takes an exclusive lock on the store for ttl, for processes that must not
+// mutate a store's tuples concurrently, such as migrations. It returns ErrLocked if the store is
+// already locked and the lock hasn't expired, whoever holds it. Locks are advisory: the
+// datastore's own writes don't check them.
+func (ds *Datastore) AcquireStoreLock(ctx context.Context, store string, ttl time.Duration) (_ *StoreLock, err error) {
+	ctx, span := ds.startTrace(ctx, "AcquireStoreLock", storeAttributes(store, LocksCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lock := &StoreLock{
+		Store:     store,
+		Token:     ulid.Make().String(),
+		ExpiresAt: now.Add(ttl),
+	}
+
+	// An expired lock is taken over in place; a live one makes the upsert insert a second
+	// document for the store, which the unique index rejects.
+	filter := bson.M{
+		"store":      store,
+		"expires_at": bson.M{"$lte": primitive.NewDateTimeFromTime(now)},
+	}
+	update := bson.M{"$set": bson.M{
+		"token":      lock.Token,
+		"expires_at": primitive.NewDateTimeFromTime(lock.ExpiresAt),
+	}}
+
+	collection := ds.collection(LocksCollection)
+	_, err = collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("acquire store lock: %w", err)
+	}
+
+	return lock, nil
+}
+
+// ReleaseStoreLock releases a lock returned by AcquireStoreLock. It returns ErrLockNotHeld if
+// the lock has expired and been removed or taken over in the meantime.
+func (ds *Datastore) ReleaseStoreLock(ctx context.Context, lock *StoreLock) (err error) {
+	ctx, span := ds.startTrace(ctx, "ReleaseStoreLock", storeAttributes(lock.Store, LocksCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return err
+	}
+
+	collection := ds.collection(LocksCollection)
+	result, err := collection.DeleteOne(ctx, bson.M{"store": lock.Store, "token": lock.Token})
+	if err != nil {
+		return fmt.Errorf("release store lock: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
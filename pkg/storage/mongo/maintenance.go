@@ -0,0 +1,123 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// FindOrphanedTuples returns the store's tuples that reference an object type, relation, user
+// type or userset relation that the given model doesn't define. It is meant for audits after a
+// model change, not for the request path: it scans the store's tuples in ULID order and returns
+// up to PageSize orphans together with a continuation token to resume the scan.
+func (ds *Datastore) FindOrphanedTuples(
+	ctx context.Context,
+	store string,
+	model *openfgav1.AuthorizationModel,
+	pagination storage.PaginationOptions,
+) (_ []*openfgav1.Tuple, _ string, err error) {
+	ctx, span := ds.startTrace(ctx, "FindOrphanedTuples", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, "", err
+	}
+
+	pageSize := ds.pageSize(pagination.PageSize)
+
+	relationsByType := make(map[string]map[string]struct{}, len(model.GetTypeDefinitions()))
+	for _, typeDef := range model.GetTypeDefinitions() {
+		relations := make(map[string]struct{}, len(typeDef.GetRelations()))
+		for relation := range typeDef.GetRelations() {
+			relations[relation] = struct{}{}
+		}
+		relationsByType[typeDef.GetType()] = relations
+	}
+
+	isDefined := func(objectType, relation string) bool {
+		relations, ok := relationsByType[objectType]
+		if !ok {
+			return false
+		}
+		if relation == "" {
+			return true
+		}
+		_, ok = relations[relation]
+		return ok
+	}
+
+	filter := bson.M{"store": store}
+	if pagination.From != "" {
+		filter["ulid"] = bson.M{"$gt": pagination.From}
+	}
+
+	collection := ds.collection(TuplesCollection)
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "ulid", Value: 1}}), ds.findTimeout())
+	if err != nil {
+		return nil, "", fmt.Errorf("find tuples: %w", unavailableError(queryTimeoutError(err)))
+	}
+	defer cursor.Close(ctx)
+
+	var orphans []*openfgav1.Tuple
+	for cursor.Next(ctx) {
+		var doc TupleDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, "", fmt.Errorf("decode tuple document: %w", err)
+		}
+
+		userType, _, userRelation := tupleUtils.ToUserParts(doc.User)
+		if isDefined(doc.ObjectType, doc.Relation) && isDefined(userType, userRelation) {
+			continue
+		}
+
+		orphans = append(orphans, docToTuple(&doc))
+		if len(orphans) == pageSize {
+			// There may be more orphans after this one.
+			setResultCount(span, len(orphans))
+			return orphans, doc.ULID, nil
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, "", fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	setResultCount(span, len(orphans))
+	return orphans, "", nil
+}
+
+// BackfillObjectRelations sets the object_relation field on tuples written before the field
+// existed, so ResolveMembershipGraph can follow them. It runs as a single server-side update
+// and returns the number of tuples updated. It is safe to run more than once.
+func (ds *Datastore) BackfillObjectRelations(ctx context.Context) (_ int64, err error) {
+	ctx, span := ds.startTrace(ctx, "BackfillObjectRelations", attribute.String(collectionAttribute, TuplesCollection))
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return 0, err
+	}
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"object_relation": bson.M{"$concat": bson.A{"$object_type", ":", "$object_id", "#", "$relation"}},
+		}}},
+	}
+
+	collection := ds.collection(TuplesCollection)
+	result, err := collection.UpdateMany(ctx, bson.M{"object_relation": bson.M{"$exists": false}}, update)
+	if err != nil {
+		return 0, fmt.Errorf("backfill object relations: %w", err)
+	}
+
+	setResultCount(span, int(result.ModifiedCount))
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,173 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// datastoreMetrics are the Prometheus metrics of a datastore created with ExportMetrics. A nil
+// *datastoreMetrics records nothing.
+type datastoreMetrics struct {
+	operationDuration *prometheus.HistogramVec
+	operationErrors   *prometheus.CounterVec
+	activeCursors     prometheus.Gauge
+	commitRetries     prometheus.Counter
+	writeWait         prometheus.Histogram
+}
+
+// newDatastoreMetrics registers the datastore metrics with registerer, or with the default
+// registerer when it is nil. Metrics that are already registered, for example by another
+// datastore in the same process, are shared rather than failing.
+func newDatastoreMetrics(registerer prometheus.Registerer) (*datastoreMetrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	operationDuration, err := registerCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:                       build.ProjectName,
+		Name:                            "mongo_operation_duration_ms",
+		Help:                            "Time (in ms) a MongoDB datastore operation took, by method.",
+		Buckets:                         []float64{1, 3, 5, 10, 25, 50, 100, 1000, 5000},
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramMaxBucketNumber:  100,
+		NativeHistogramMinResetDuration: time.Hour,
+	}, []string{"method"}))
+	if err != nil {
+		return nil, err
+	}
+
+	operationErrors, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "mongo_operation_error_count",
+		Help:      "The total number of MongoDB datastore operations that failed, by method and error type.",
+	}, []string{"method", "type"}))
+	if err != nil {
+		return nil, err
+	}
+
+	activeCursors, err := registerCollector(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: build.ProjectName,
+		Name:      "mongo_active_cursors",
+		Help:      "The number of tuple iterators whose MongoDB cursor is still open.",
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	commitRetries, err := registerCollector(registerer, prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "mongo_transaction_commit_retry_count",
+		Help:      "The total number of MongoDB transaction commits retried after an UnknownTransactionCommitResult error.",
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	writeWait, err := registerCollector(registerer, prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace:                       build.ProjectName,
+		Name:                            "mongo_write_limiter_wait_ms",
+		Help:                            "Time (in ms) a Write spent waiting for its store's concurrent write limit.",
+		Buckets:                         []float64{1, 3, 5, 10, 25, 50, 100, 1000, 5000},
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramMaxBucketNumber:  100,
+		NativeHistogramMinResetDuration: time.Hour,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &datastoreMetrics{
+		operationDuration: operationDuration,
+		operationErrors:   operationErrors,
+		activeCursors:     activeCursors,
+		commitRetries:     commitRetries,
+		writeWait:         writeWait,
+	}, nil
+}
+
+// registerCollector registers collector, or returns the equivalent collector registered before.
+func registerCollector[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	err := registerer.Register(collector)
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		if existing, ok := registered.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("initialize metrics: %w", err)
+	}
+	return collector, nil
+}
+
+// observeDuration records how long an operation took.
+func (m *datastoreMetrics) observeDuration(method string, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.operationDuration.WithLabelValues(method).Observe(float64(time.Since(start).Milliseconds()))
+}
+
+// countError records a failed operation. Not finding anything is not counted.
+func (m *datastoreMetrics) countError(method string, err error) {
+	if m == nil || err == nil || errors.Is(err, storage.ErrNotFound) {
+		return
+	}
+	m.operationErrors.WithLabelValues(method, errorType(err)).Inc()
+}
+
+// cursorOpened and cursorClosed track the cursors of open tuple iterators.
+func (m *datastoreMetrics) cursorOpened() {
+	if m != nil {
+		m.activeCursors.Inc()
+	}
+}
+
+func (m *datastoreMetrics) cursorClosed() {
+	if m != nil {
+		m.activeCursors.Dec()
+	}
+}
+
+// commitRetried records a transaction commit retried after an unknown commit result.
+func (m *datastoreMetrics) commitRetried() {
+	if m != nil {
+		m.commitRetries.Inc()
+	}
+}
+
+// observeWriteWait records how long a write waited for its store's write slot.
+func (m *datastoreMetrics) observeWriteWait(start time.Time) {
+	if m != nil {
+		m.writeWait.Observe(float64(time.Since(start).Milliseconds()))
+	}
+}
+
+// errorType classifies an error for the type label of the error counter.
+func errorType(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case mongo.IsTimeout(err):
+		return "timeout"
+	case mongo.IsNetworkError(err):
+		return "network"
+	case errors.Is(err, storage.ErrCollision), mongo.IsDuplicateKeyError(err):
+		return "collision"
+	case errors.Is(err, storage.ErrInvalidWriteInput),
+		errors.Is(err, storage.ErrExceededWriteBatchLimit),
+		errors.Is(err, storage.ErrInvalidContinuationToken):
+		return "invalid_input"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,212 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/openfga/openfga/pkg/logger"
+)
+
+// MigrationReport lists the collections and indexes a migration created and those that were
+// already present. Indexes are named by their description, such as "tuple" or "reverse tuple".
+type MigrationReport struct {
+	CreatedCollections  []string `json:"created_collections"`
+	ExistingCollections []string `json:"existing_collections"`
+	CreatedIndexes      []string `json:"created_indexes"`
+	ExistingIndexes     []string `json:"existing_indexes"`
+	// SplitObjects counts the tuples and changelog entries whose combined object field was split
+	// into object_type and object_id.
+	SplitObjects int64 `json:"split_objects"`
+	// UserTypes counts the userset and wildcard tuples given the user_type field they were
+	// written without.
+	UserTypes int64 `json:"user_types"`
+	// SchemaVersion is the schema version recorded once the migration completed.
+	SchemaVersion int `json:"schema_version"`
+}
+
+// collectionNames lists every collection the datastore uses.
+func collectionNames() []string {
+	return []string{
+		TuplesCollection,
+		AuthorizationModelsCollection,
+		StoresCollection,
+		AssertionsCollection,
+		ChangelogCollection,
+		StoreSettingsCollection,
+		LeasesCollection,
+		LocksCollection,
+		SchemaMetaCollection,
+		IdempotencyKeysCollection,
+	}
+}
+
+// RunMigrations provisions the collections and indexes of the datastore in database dbName,
+// without opening a Datastore, so that a migrate command can run it before the server starts.
+// Only the logger, ForegroundIndexBuilds, IndexCreateRetries and CollectionPrefix options are used. It is safe to
+// run repeatedly and concurrently.
+func RunMigrations(ctx context.Context, client *mongo.Client, dbName string, opts ...ConfigOption) (*MigrationReport, error) {
+	if dbName == "" {
+		return nil, errors.New("database name is required")
+	}
+
+	cfg := &Config{Database: dbName}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = logger.NewNoopLogger()
+	}
+	if err := validateCollectionPrefix(cfg.CollectionPrefix, dbName); err != nil {
+		return nil, err
+	}
+
+	ds := &Datastore{
+		client:                client,
+		database:              client.Database(dbName, options.Database().SetRegistry(newRegistry(false))),
+		logger:                cfg.Logger,
+		foregroundIndexBuilds: cfg.ForegroundIndexBuilds,
+		indexCreateRetries:    cfg.IndexCreateRetries,
+		collectionPrefix:      cfg.CollectionPrefix,
+	}
+	return ds.Migrate(ctx)
+}
+
+// Migrate creates the datastore's collections and indexes that don't exist yet, brings existing
+// documents to ExpectedSchemaVersion and records that version in the schema_meta collection. It
+// reports which collections and indexes it created and which were already present.
+func (ds *Datastore) Migrate(ctx context.Context) (_ *MigrationReport, err error) {
+	ctx, span := ds.startTrace(ctx, "Migrate")
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	existing, err := ds.database.ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("list collections: %w", err)
+	}
+
+	report := &MigrationReport{}
+	for _, name := range collectionNames() {
+		name = ds.collectionName(name)
+		if slices.Contains(existing, name) {
+			report.ExistingCollections = append(report.ExistingCollections, name)
+			continue
+		}
+
+		err := ds.database.CreateCollection(ctx, name)
+		var cmdErr mongo.CommandError
+		switch {
+		case err == nil:
+			report.CreatedCollections = append(report.CreatedCollections, name)
+		case errors.As(err, &cmdErr) && cmdErr.Code == mongoNamespaceExistsCode:
+			// Created by a concurrent migration since the listing.
+			report.ExistingCollections = append(report.ExistingCollections, name)
+		default:
+			return nil, fmt.Errorf("create %s collection: %w", name, err)
+		}
+	}
+
+	// Split objects before building the indexes, which key tuples on object_type and object_id.
+	for _, name := range []string{TuplesCollection, ChangelogCollection} {
+		split, err := splitObjectFields(ctx, ds.collection(name))
+		if err != nil {
+			return nil, fmt.Errorf("split objects in %s collection: %w", name, err)
+		}
+		report.SplitObjects += split
+	}
+
+	userTypes, err := backfillUserTypes(ctx, ds.collection(TuplesCollection))
+	if err != nil {
+		return nil, fmt.Errorf("backfill user types in %s collection: %w", TuplesCollection, err)
+	}
+	report.UserTypes = userTypes
+
+	if err := ds.ensureIndexes(ctx, report); err != nil {
+		return nil, err
+	}
+
+	// Only recorded once every step succeeded, so that a failed migration is run again.
+	if err := ds.recordSchemaVersion(ctx); err != nil {
+		return nil, err
+	}
+	if report.SchemaVersion, _, err = ds.schemaVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// splitObjectFields backfills object_type and object_id in documents that only have a combined
+// object field ("document:budget-2024"), as written by tools that predate the split, and removes
+// the combined field. It splits on the first ':' like tupleUtils.SplitObject, so an id with
+// colons of its own ("document:2024:q1") stays whole. The update runs server-side.
+func splitObjectFields(ctx context.Context, collection *mongo.Collection) (int64, error) {
+	separator := bson.M{"$indexOfCP": bson.A{"$object", ":"}}
+	hasSeparator := bson.M{"$gte": bson.A{separator, 0}}
+
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"object": bson.M{"$type": "string"}, "object_type": bson.M{"$exists": false}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"object_type": bson.M{"$cond": bson.A{
+					hasSeparator,
+					bson.M{"$substrCP": bson.A{"$object", 0, separator}},
+					"",
+				}},
+				"object_id": bson.M{"$cond": bson.A{
+					hasSeparator,
+					bson.M{"$substrCP": bson.A{
+						"$object",
+						bson.M{"$add": bson.A{separator, 1}},
+						bson.M{"$strLenCP": "$object"},
+					}},
+					"$object",
+				}},
+			}}},
+			{{Key: "$unset", Value: "object"}},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// backfillUserTypes sets user_type on userset and wildcard tuples written before the field
+// existed, the way tupleKeyToDoc does: the user's type and its relation after the last '#' for
+// a userset, the user itself for a typed wildcard. The update runs server-side.
+func backfillUserTypes(ctx context.Context, collection *mongo.Collection) (int64, error) {
+	userset := bson.M{"$regexFind": bson.M{"input": "$user", "regex": `^([^:]*):.*#([^#]+)$`}}
+
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"user_type": bson.M{"$exists": false}, "user": bson.M{"$regex": `^[^:#]+:(\*|.*#[^#]+)$`}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"user_type": bson.M{"$let": bson.M{
+					"vars": bson.M{"userset": userset},
+					"in": bson.M{"$cond": bson.A{
+						bson.M{"$eq": bson.A{"$$userset", nil}},
+						"$user",
+						bson.M{"$concat": bson.A{
+							bson.M{"$arrayElemAt": bson.A{"$$userset.captures", 0}},
+							"#",
+							bson.M{"$arrayElemAt": bson.A{"$$userset.captures", 1}},
+						}},
+					}},
+				}},
+			}}},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
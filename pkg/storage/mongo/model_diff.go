@@ -0,0 +1,163 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+// AuthorizationModelDiff describes how one authorization model differs from another. Names in
+// every list are sorted.
+type AuthorizationModelDiff struct {
+	FromModelID         string     `json:"from_model_id"`
+	ToModelID           string     `json:"to_model_id"`
+	SchemaVersionChange *[2]string `json:"schema_version_change,omitempty"`
+	AddedTypes          []string   `json:"added_types,omitempty"`
+	RemovedTypes        []string   `json:"removed_types,omitempty"`
+	ChangedTypes        []TypeDiff `json:"changed_types,omitempty"`
+	AddedConditions     []string   `json:"added_conditions,omitempty"`
+	RemovedConditions   []string   `json:"removed_conditions,omitempty"`
+	ChangedConditions   []string   `json:"changed_conditions,omitempty"`
+}
+
+// TypeDiff lists the relation changes of a type present in both models. A relation is changed
+// when its rewrite or its directly related user types differ.
+type TypeDiff struct {
+	Type             string   `json:"type"`
+	AddedRelations   []string `json:"added_relations,omitempty"`
+	RemovedRelations []string `json:"removed_relations,omitempty"`
+	ChangedRelations []string `json:"changed_relations,omitempty"`
+}
+
+// Empty reports whether the two models are equivalent.
+func (d *AuthorizationModelDiff) Empty() bool {
+	return d.SchemaVersionChange == nil &&
+		len(d.AddedTypes) == 0 && len(d.RemovedTypes) == 0 && len(d.ChangedTypes) == 0 &&
+		len(d.AddedConditions) == 0 && len(d.RemovedConditions) == 0 && len(d.ChangedConditions) == 0
+}
+
+// DiffAuthorizationModels reads two of the store's authorization models and reports what changed
+// from the first to the second: added, removed and changed type definitions, relations and
+// conditions. It returns storage.ErrNotFound if either model doesn't exist.
+func (ds *Datastore) DiffAuthorizationModels(ctx context.Context, store, fromModelID, toModelID string) (_ *AuthorizationModelDiff, err error) {
+	ctx, span := ds.startTrace(ctx, "DiffAuthorizationModels", storeAttributes(store, AuthorizationModelsCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	from, err := ds.ReadAuthorizationModel(ctx, store, fromModelID)
+	if err != nil {
+		return nil, fmt.Errorf("read model '%s': %w", fromModelID, err)
+	}
+	to, err := ds.ReadAuthorizationModel(ctx, store, toModelID)
+	if err != nil {
+		return nil, fmt.Errorf("read model '%s': %w", toModelID, err)
+	}
+
+	return diffAuthorizationModels(from, to), nil
+}
+
+// diffAuthorizationModels compares two models.
+func diffAuthorizationModels(from, to *openfgav1.AuthorizationModel) *AuthorizationModelDiff {
+	diff := &AuthorizationModelDiff{
+		FromModelID: from.GetId(),
+		ToModelID:   to.GetId(),
+	}
+
+	if from.GetSchemaVersion() != to.GetSchemaVersion() {
+		diff.SchemaVersionChange = &[2]string{from.GetSchemaVersion(), to.GetSchemaVersion()}
+	}
+
+	fromTypes := typeDefinitionsByName(from)
+	toTypes := typeDefinitionsByName(to)
+	diff.AddedTypes, diff.RemovedTypes = addedAndRemoved(fromTypes, toTypes)
+	for _, name := range sortedNames(fromTypes) {
+		toType, ok := toTypes[name]
+		if !ok {
+			continue
+		}
+		if typeDiff := diffTypeDefinitions(fromTypes[name], toType); typeDiff != nil {
+			diff.ChangedTypes = append(diff.ChangedTypes, *typeDiff)
+		}
+	}
+
+	fromConditions, toConditions := from.GetConditions(), to.GetConditions()
+	diff.AddedConditions, diff.RemovedConditions = addedAndRemoved(fromConditions, toConditions)
+	for _, name := range sortedNames(fromConditions) {
+		if toCondition, ok := toConditions[name]; ok && !proto.Equal(fromConditions[name], toCondition) {
+			diff.ChangedConditions = append(diff.ChangedConditions, name)
+		}
+	}
+
+	return diff
+}
+
+// diffTypeDefinitions compares the relations of two versions of a type, returning nil if they
+// are the same.
+func diffTypeDefinitions(from, to *openfgav1.TypeDefinition) *TypeDiff {
+	fromRelations, toRelations := from.GetRelations(), to.GetRelations()
+
+	typeDiff := &TypeDiff{Type: from.GetType()}
+	typeDiff.AddedRelations, typeDiff.RemovedRelations = addedAndRemoved(fromRelations, toRelations)
+	for _, relation := range sortedNames(fromRelations) {
+		toRewrite, ok := toRelations[relation]
+		if !ok {
+			continue
+		}
+		fromMetadata := from.GetMetadata().GetRelations()[relation]
+		toMetadata := to.GetMetadata().GetRelations()[relation]
+		if !proto.Equal(fromRelations[relation], toRewrite) ||
+			!slices.EqualFunc(fromMetadata.GetDirectlyRelatedUserTypes(), toMetadata.GetDirectlyRelatedUserTypes(), relationReferenceEqual) {
+			typeDiff.ChangedRelations = append(typeDiff.ChangedRelations, relation)
+		}
+	}
+
+	if len(typeDiff.AddedRelations) == 0 && len(typeDiff.RemovedRelations) == 0 && len(typeDiff.ChangedRelations) == 0 {
+		return nil
+	}
+	return typeDiff
+}
+
+func relationReferenceEqual(a, b *openfgav1.RelationReference) bool {
+	return proto.Equal(a, b)
+}
+
+func typeDefinitionsByName(model *openfgav1.AuthorizationModel) map[string]*openfgav1.TypeDefinition {
+	types := make(map[string]*openfgav1.TypeDefinition, len(model.GetTypeDefinitions()))
+	for _, typeDef := range model.GetTypeDefinitions() {
+		types[typeDef.GetType()] = typeDef
+	}
+	return types
+}
+
+// addedAndRemoved returns the sorted keys only in to and only in from.
+func addedAndRemoved[V any](from, to map[string]V) (added, removed []string) {
+	for _, name := range sortedNames(to) {
+		if _, ok := from[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	for _, name := range sortedNames(from) {
+		if _, ok := to[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	return added, removed
+}
+
+// sortedNames returns the keys of the map in ascending order.
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,69 @@
+package mongo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/testutils"
+)
+
+func TestDiffAuthorizationModels(t *testing.T) {
+	from := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type team
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [user]
+				define editor: [user]
+				define owner: [user]
+		condition in_office(ip: ipaddress) {
+			ip.in_cidr("10.0.0.0/8")
+		}
+		condition weekdays(day: int) {
+			day < 6
+		}`)
+	to := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [user, group#member]
+				define editor: [user] or owner
+				define owner: [user]
+				define commenter: [user]
+		condition in_office(ip: ipaddress) {
+			ip.in_cidr("192.168.0.0/16")
+		}
+		condition expiring(now: timestamp) {
+			now < timestamp("2030-01-01T00:00:00Z")
+		}`)
+
+	diff := diffAuthorizationModels(from, to)
+	require.False(t, diff.Empty())
+	require.Nil(t, diff.SchemaVersionChange)
+	require.Equal(t, []string{"group"}, diff.AddedTypes)
+	require.Equal(t, []string{"team"}, diff.RemovedTypes)
+	require.Equal(t, []TypeDiff{{
+		Type:             "document",
+		AddedRelations:   []string{"commenter"},
+		ChangedRelations: []string{"editor", "viewer"},
+	}}, diff.ChangedTypes)
+	require.Equal(t, []string{"expiring"}, diff.AddedConditions)
+	require.Equal(t, []string{"weekdays"}, diff.RemovedConditions)
+	require.Equal(t, []string{"in_office"}, diff.ChangedConditions)
+
+	_, err := json.Marshal(diff)
+	require.NoError(t, err)
+
+	require.True(t, diffAuthorizationModels(from, from).Empty())
+}
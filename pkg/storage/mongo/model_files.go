@@ -0,0 +1,106 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+// ModelFilesBucket is the GridFS bucket holding the serialized authorization models larger than
+// GridFSModelThreshold. Its files and chunks collections get the collection prefix too.
+const ModelFilesBucket = "authorization_model_files"
+
+// modelFiles returns the GridFS bucket of large models. GridFS operations don't take a context,
+// so the context's deadline, if any, is applied to the bucket instead.
+func (ds *Datastore) modelFiles(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(ds.database, options.GridFSBucket().SetName(ds.collectionName(ModelFilesBucket)))
+	if err != nil {
+		return nil, fmt.Errorf("open model files bucket: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := bucket.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		if err := bucket.SetWriteDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	return bucket, nil
+}
+
+// uploadModel stores a serialized model in GridFS and returns the file's id. The store and model
+// id are kept in the file's metadata, so that purging a store can find its files.
+func (ds *Datastore) uploadModel(ctx context.Context, store, id string, serialized []byte) (primitive.ObjectID, error) {
+	bucket, err := ds.modelFiles(ctx)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	opts := options.GridFSUpload().SetMetadata(bson.M{"store": store, "model_id": id})
+	return bucket.UploadFromStream(store+"/"+id, bytes.NewReader(serialized), opts)
+}
+
+// deleteModelFile removes a model's GridFS file, logging rather than returning a failure: it
+// only runs to clean up after a write that failed for another reason.
+func (ds *Datastore) deleteModelFile(ctx context.Context, fileID primitive.ObjectID) {
+	bucket, err := ds.modelFiles(ctx)
+	if err == nil {
+		err = bucket.DeleteContext(ctx, fileID)
+	}
+	if err != nil {
+		ds.logger.Warn("failed to delete mongodb model file", zap.String("file_id", fileID.Hex()), zap.Error(err))
+	}
+}
+
+// purgeModelFiles removes the GridFS files of every model of the store and returns how many it
+// removed.
+func (ds *Datastore) purgeModelFiles(ctx context.Context, store string) (int64, error) {
+	bucket, err := ds.modelFiles(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cursor, err := bucket.FindContext(ctx, bson.M{"metadata.store": store})
+	if err != nil {
+		return 0, fmt.Errorf("find model files: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var deleted int64
+	for cursor.Next(ctx) {
+		var file struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&file); err != nil {
+			return deleted, fmt.Errorf("decode model file: %w", err)
+		}
+		if err := bucket.DeleteContext(ctx, file.ID); err != nil {
+			return deleted, fmt.Errorf("delete model file %s: %w", file.ID.Hex(), err)
+		}
+		deleted++
+	}
+	return deleted, cursor.Err()
+}
+
+// loadModel decodes the model stored in the document, first reading its serialized form back
+// from GridFS when it was too large to store inline.
+func (ds *Datastore) loadModel(ctx context.Context, doc *AuthorizationModelDocument) (*openfgav1.AuthorizationModel, error) {
+	if doc.SerializedFile != nil {
+		bucket, err := ds.modelFiles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if _, err := bucket.DownloadToStream(*doc.SerializedFile, &buf); err != nil {
+			return nil, fmt.Errorf("download authorization model %s: %w", doc.ID, err)
+		}
+		doc.Serialized = buf.Bytes()
+	}
+	return doc.toModel()
+}
@@ -0,0 +1,73 @@
+package mongo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/protobuf/proto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+// modelHash returns a hex SHA-256 of the model's content: its schema version, type definitions
+// and conditions, but not its id. Type definitions are sorted by type, since their order doesn't
+// change the model, and maps are marshalled in key order, so equal models always hash the same.
+func modelHash(model *openfgav1.AuthorizationModel) (string, error) {
+	content := &openfgav1.AuthorizationModel{
+		SchemaVersion:   model.GetSchemaVersion(),
+		TypeDefinitions: slices.Clone(model.GetTypeDefinitions()),
+		Conditions:      model.GetConditions(),
+	}
+	slices.SortStableFunc(content.TypeDefinitions, func(a, b *openfgav1.TypeDefinition) int {
+		return strings.Compare(a.GetType(), b.GetType())
+	})
+
+	serialized, err := proto.MarshalOptions{Deterministic: true}.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("marshal authorization model for hashing: %w", err)
+	}
+	sum := sha256.Sum256(serialized)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// latestModelHash returns the id and hash of the store's latest model, or nil when the store has
+// none. Only the hash is fetched, except for a model written before hashes were recorded, which
+// is read and hashed.
+func (ds *Datastore) latestModelHash(ctx context.Context, store string) (*AuthorizationModelDocument, error) {
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "id", Value: -1}}).
+		SetHint(authorizationModelIndexKeys).
+		SetProjection(bson.M{"id": 1, "hash": 1})
+
+	collection := ds.collection(AuthorizationModelsCollection)
+	var doc AuthorizationModelDocument
+	err := ds.retry(ctx, func() error {
+		return collection.FindOne(ctx, bson.M{"store": store}, opts, ds.findOneTimeout()).Decode(&doc)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find latest authorization model: %w", err)
+	}
+	if doc.Hash != "" {
+		return &doc, nil
+	}
+
+	model, err := ds.ReadAuthorizationModel(ctx, store, doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Hash, err = modelHash(model); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
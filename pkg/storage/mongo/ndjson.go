@@ -0,0 +1,232 @@
+package mongo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// maxNDJSONLineSize bounds a line read by ImportTuplesNDJSON. A tuple, condition context
+// included, fits in a MongoDB document, so its line is never longer than one.
+const maxNDJSONLineSize = 16 * 1024 * 1024
+
+// NDJSONTuple is one line of an NDJSON tuple export. The format is stable: fields may be added,
+// and are ignored by older importers, but existing fields keep their name and meaning.
+type NDJSONTuple struct {
+	Object    string           `json:"object"`
+	Relation  string           `json:"relation"`
+	User      string           `json:"user"`
+	Condition *NDJSONCondition `json:"condition,omitempty"`
+	// ExpiresAt is the expiry of a tuple written with WriteWithExpiry, in RFC 3339.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// NDJSONCondition is the condition of an NDJSONTuple, with its context as a JSON object.
+type NDJSONCondition struct {
+	Name    string                 `json:"name"`
+	Context map[string]interface{} `json:"context,omitempty"`
+}
+
+// ExportTuplesNDJSON streams the store's tuples matching the filter to w as newline-delimited
+// JSON, one NDJSONTuple per line, for backups that ImportTuplesNDJSON restores. Unlike the CSV
+// export it keeps condition contexts. Tuples are read through a server-side cursor in ULID
+// order, never held in memory all at once, and written through a buffer flushed once per cursor
+// batch, so a slow writer holds the export back rather than the export buffering the store. The
+// export stops with the context's error if it is cancelled.
+func (ds *Datastore) ExportTuplesNDJSON(ctx context.Context, store string, w io.Writer, filter *openfgav1.TupleKey) (err error) {
+	ctx, span := ds.startTrace(ctx, "ExportTuplesNDJSON", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return err
+	}
+
+	cursor, err := ds.exportCursor(ctx, store, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	encoder.SetEscapeHTML(false)
+
+	lines := 0
+	for cursor.Next(ctx) {
+		var doc TupleDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return fmt.Errorf("decode tuple document: %w", err)
+		}
+		if err := encoder.Encode(docToNDJSON(&doc)); err != nil {
+			return fmt.Errorf("write export line: %w", err)
+		}
+
+		// Flush once per cursor batch so the output streams to slow readers. The documents of a
+		// batch are already fetched, so the context is checked here too.
+		if lines++; lines%exportBatchSize == 0 {
+			if err := buffered.Flush(); err != nil {
+				return fmt.Errorf("flush export: %w", err)
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("flush export: %w", err)
+	}
+
+	setResultCount(span, lines)
+	return nil
+}
+
+// ImportTuplesNDJSON imports the tuples of an NDJSON export, one NDJSONTuple per line, with
+// ImportTuples: they are validated, batched, counted and resumed the same way. Tuples keep
+// their condition context and expiry; a tuple whose expiry has passed is counted as failed.
+// Blank lines are skipped, and a line that isn't a tuple stops the import with an error wrapping
+// storage.ErrInvalidWriteInput that names the line.
+func (ds *Datastore) ImportTuplesNDJSON(ctx context.Context, store string, r io.Reader, opts ImportOptions) (_ *ImportResult, err error) {
+	ctx, span := ds.startTrace(ctx, "ImportTuplesNDJSON", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	tuples := newNDJSONTupleIterator(r)
+	return ds.importTuples(ctx, store, tuples, opts, tuples.expiry)
+}
+
+// docToNDJSON converts a tuple document to its export line.
+func docToNDJSON(doc *TupleDocument) *NDJSONTuple {
+	line := &NDJSONTuple{
+		Object:   tupleUtils.BuildObject(doc.ObjectType, doc.ObjectID),
+		Relation: doc.Relation,
+		User:     doc.User,
+	}
+	if doc.Condition != nil {
+		line.Condition = &NDJSONCondition{
+			Name:    doc.Condition.GetName(),
+			Context: doc.Condition.GetContext().AsMap(),
+		}
+		if len(line.Condition.Context) == 0 {
+			line.Condition.Context = nil
+		}
+	}
+	if doc.ExpiresAt != nil {
+		expiresAt := doc.ExpiresAt.Time().UTC()
+		line.ExpiresAt = &expiresAt
+	}
+	return line
+}
+
+// ndjsonTupleIterator is a storage.TupleKeyIterator over the lines of an NDJSON export. It
+// remembers the expiry of the tuples it returned until expiry is asked for it.
+type ndjsonTupleIterator struct {
+	scanner  *bufio.Scanner
+	line     int
+	head     *openfgav1.TupleKey
+	stopped  bool
+	expiries map[string]primitive.DateTime
+}
+
+func newNDJSONTupleIterator(r io.Reader) *ndjsonTupleIterator {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineSize)
+	return &ndjsonTupleIterator{scanner: scanner, expiries: map[string]primitive.DateTime{}}
+}
+
+// Next see [storage.Iterator].Next.
+func (it *ndjsonTupleIterator) Next(ctx context.Context) (*openfgav1.TupleKey, error) {
+	tk, err := it.Head(ctx)
+	it.head = nil
+	return tk, err
+}
+
+// Head see [storage.Iterator].Head.
+func (it *ndjsonTupleIterator) Head(ctx context.Context) (*openfgav1.TupleKey, error) {
+	if it.head != nil {
+		return it.head, nil
+	}
+	if it.stopped {
+		return nil, storage.ErrIteratorDone
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for it.scanner.Scan() {
+		it.line++
+		data := bytes.TrimSpace(it.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		tk, expiresAt, err := parseNDJSONTuple(data)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", it.line, err)
+		}
+		if expiresAt != nil {
+			it.expiries[tupleUtils.TupleKeyToString(tk)] = primitive.NewDateTimeFromTime(*expiresAt)
+		}
+		it.head = tk
+		return tk, nil
+	}
+	if err := it.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("line %d: longer than %d bytes: %w", it.line+1, maxNDJSONLineSize, storage.ErrInvalidWriteInput)
+		}
+		return nil, fmt.Errorf("read import: %w", err)
+	}
+	return nil, storage.ErrIteratorDone
+}
+
+// Stop see [storage.Iterator].Stop.
+func (it *ndjsonTupleIterator) Stop() {
+	it.stopped = true
+	it.head = nil
+}
+
+// expiry returns the expiry read with the tuple, if it had one, and forgets it.
+func (it *ndjsonTupleIterator) expiry(tk *openfgav1.TupleKey) *primitive.DateTime {
+	key := tupleUtils.TupleKeyToString(tk)
+	expiresAt, ok := it.expiries[key]
+	if !ok {
+		return nil
+	}
+	delete(it.expiries, key)
+	return &expiresAt
+}
+
+// parseNDJSONTuple decodes one line of an NDJSON export into its tuple key and expiry.
+func parseNDJSONTuple(data []byte) (*openfgav1.TupleKey, *time.Time, error) {
+	var line NDJSONTuple
+	if err := json.Unmarshal(data, &line); err != nil {
+		return nil, nil, fmt.Errorf("decode tuple: %v: %w", err, storage.ErrInvalidWriteInput)
+	}
+
+	tk := tupleUtils.NewTupleKey(line.Object, line.Relation, line.User)
+	if line.Condition != nil {
+		var conditionContext *structpb.Struct
+		if len(line.Condition.Context) > 0 {
+			var err error
+			if conditionContext, err = structpb.NewStruct(line.Condition.Context); err != nil {
+				return nil, nil, fmt.Errorf("decode condition context: %v: %w", err, storage.ErrInvalidWriteInput)
+			}
+		}
+		tk.Condition = &openfgav1.RelationshipCondition{Name: line.Condition.Name, Context: conditionContext}
+	}
+	return tk, line.ExpiresAt, nil
+}
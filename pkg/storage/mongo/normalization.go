@@ -0,0 +1,132 @@
+package mongo
+
+import (
+	"strings"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+const (
+	// IdentifierNormalizationPreserve stores and matches objects and users exactly as given. It
+	// is the default.
+	IdentifierNormalizationPreserve = "preserve"
+	// IdentifierNormalizationLowercaseType lowercases the type of every object and user, so that
+	// "User:Alice" is stored and matched as "user:Alice". Object ids, user ids and relations keep
+	// their case, since the systems they come from often treat them as case-sensitive.
+	IdentifierNormalizationLowercaseType = "lowercase_type"
+)
+
+// lowercaseTypes reports whether identifier types are lowercased on writes and reads.
+func (ds *Datastore) lowercaseTypes() bool {
+	return ds.identifierNormalization == IdentifierNormalizationLowercaseType
+}
+
+// normalizeType returns an object type in its canonical form.
+func (ds *Datastore) normalizeType(objectType string) string {
+	if !ds.lowercaseTypes() {
+		return objectType
+	}
+	return strings.ToLower(objectType)
+}
+
+// normalizeObject returns an object ("document:1"), user ("user:anne", "user:*") or userset
+// ("group:eng#member") in its canonical form. Only the part before the first ':' is the type;
+// a value without one, such as the bare "*" of old tuples, is returned unchanged.
+func (ds *Datastore) normalizeObject(object string) string {
+	if !ds.lowercaseTypes() {
+		return object
+	}
+	objectType, rest, ok := strings.Cut(object, ":")
+	if !ok {
+		return object
+	}
+	return strings.ToLower(objectType) + ":" + rest
+}
+
+// normalizeTupleKey returns the tuple key with its object and user in their canonical form.
+// The key is copied rather than modified, since it belongs to the caller.
+func (ds *Datastore) normalizeTupleKey(tupleKey *openfgav1.TupleKey) *openfgav1.TupleKey {
+	if !ds.lowercaseTypes() || tupleKey == nil {
+		return tupleKey
+	}
+	return &openfgav1.TupleKey{
+		Object:    ds.normalizeObject(tupleKey.GetObject()),
+		Relation:  tupleKey.GetRelation(),
+		User:      ds.normalizeObject(tupleKey.GetUser()),
+		Condition: tupleKey.GetCondition(),
+	}
+}
+
+// normalizeWrites returns the writes and deletes of a Write with their tuple keys in canonical
+// form, so that the tuples are stored the way reads will match them.
+func (ds *Datastore) normalizeWrites(deletes storage.Deletes, writes storage.Writes) (storage.Deletes, storage.Writes) {
+	if !ds.lowercaseTypes() {
+		return deletes, writes
+	}
+
+	normalizedDeletes := make(storage.Deletes, 0, len(deletes))
+	for _, del := range deletes {
+		normalizedDeletes = append(normalizedDeletes, &openfgav1.TupleKeyWithoutCondition{
+			Object:   ds.normalizeObject(del.GetObject()),
+			Relation: del.GetRelation(),
+			User:     ds.normalizeObject(del.GetUser()),
+		})
+	}
+
+	normalizedWrites := make(storage.Writes, 0, len(writes))
+	for _, write := range writes {
+		normalizedWrites = append(normalizedWrites, ds.normalizeTupleKey(write))
+	}
+
+	return normalizedDeletes, normalizedWrites
+}
+
+// normalizeUserFilter returns the users of a ReadStartingWithUser filter with their types in
+// canonical form.
+func (ds *Datastore) normalizeUserFilter(userFilter []*openfgav1.ObjectRelation) []*openfgav1.ObjectRelation {
+	if !ds.lowercaseTypes() {
+		return userFilter
+	}
+	normalized := make([]*openfgav1.ObjectRelation, 0, len(userFilter))
+	for _, user := range userFilter {
+		normalized = append(normalized, &openfgav1.ObjectRelation{
+			Object:   ds.normalizeObject(user.GetObject()),
+			Relation: user.GetRelation(),
+		})
+	}
+	return normalized
+}
+
+// normalizeRestrictions returns the user type restrictions of a ReadUsersetTuples filter with
+// their types in canonical form.
+func (ds *Datastore) normalizeRestrictions(restrictions []*openfgav1.RelationReference) []*openfgav1.RelationReference {
+	if !ds.lowercaseTypes() {
+		return restrictions
+	}
+	normalized := make([]*openfgav1.RelationReference, 0, len(restrictions))
+	for _, restriction := range restrictions {
+		copied := &openfgav1.RelationReference{Type: ds.normalizeType(restriction.GetType())}
+		switch {
+		case restriction.GetWildcard() != nil:
+			copied.RelationOrWildcard = &openfgav1.RelationReference_Wildcard{Wildcard: restriction.GetWildcard()}
+		case restriction.GetRelation() != "":
+			copied.RelationOrWildcard = &openfgav1.RelationReference_Relation{Relation: restriction.GetRelation()}
+		}
+		normalized = append(normalized, copied)
+	}
+	return normalized
+}
+
+// normalizeUsers returns the users with their types in canonical form.
+func (ds *Datastore) normalizeUsers(users []string) []string {
+	if !ds.lowercaseTypes() {
+		return users
+	}
+	normalized := make([]string, 0, len(users))
+	for _, user := range users {
+		normalized = append(normalized, ds.normalizeObject(user))
+	}
+	return normalized
+}
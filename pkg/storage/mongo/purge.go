@@ -0,0 +1,233 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultStorePurgeInterval = time.Hour
+	// storePurgeLeaseID identifies the lease that elects the single instance running a purge round.
+	storePurgeLeaseID = "store_purge"
+)
+
+// StorePurgeReport counts the documents removed by PurgeStore, per collection.
+type StorePurgeReport struct {
+	Tuples              int64 `json:"tuples"`
+	AuthorizationModels int64 `json:"authorization_models"`
+	// ModelFiles counts the GridFS files of models above GridFSModelThreshold.
+	ModelFiles    int64 `json:"model_files"`
+	Assertions    int64 `json:"assertions"`
+	Changelog     int64 `json:"changelog"`
+	StoreSettings int64 `json:"store_settings"`
+	Stores        int64 `json:"stores"`
+}
+
+// PurgeStore permanently deletes a store and everything it owns: tuples, authorization models
+// (with their GridFS files), assertions, changelog entries and settings. The store document is
+// removed last, so a purge that fails part way can simply be run again.
+func (ds *Datastore) PurgeStore(ctx context.Context, id string) (_ *StorePurgeReport, err error) {
+	ctx, span := ds.startTrace(ctx, "PurgeStore", storeAttributes(id, StoresCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	report := &StorePurgeReport{}
+
+	// Model files are found by the store in their metadata, not through the model documents.
+	report.ModelFiles, err = ds.purgeModelFiles(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("purge model files: %w", err)
+	}
+
+	if err := ds.purgeStoreDocuments(ctx, id, report); err != nil {
+		return nil, err
+	}
+	ds.storeSettingsCache.Delete(id)
+
+	return report, nil
+}
+
+// purgeStoreDocuments deletes the store's documents from every collection, counting them in the
+// report, and the store document last.
+func (ds *Datastore) purgeStoreDocuments(ctx context.Context, id string, report *StorePurgeReport) error {
+	for _, owned := range []struct {
+		collection string
+		deleted    *int64
+	}{
+		{TuplesCollection, &report.Tuples},
+		{AuthorizationModelsCollection, &report.AuthorizationModels},
+		{AssertionsCollection, &report.Assertions},
+		{ChangelogCollection, &report.Changelog},
+		{StoreSettingsCollection, &report.StoreSettings},
+	} {
+		result, err := ds.collection(owned.collection).DeleteMany(ctx, bson.M{"store": id})
+		if err != nil {
+			return fmt.Errorf("purge %s: %w", owned.collection, err)
+		}
+		*owned.deleted = result.DeletedCount
+	}
+
+	result, err := ds.collection(StoresCollection).DeleteOne(ctx, bson.M{"id": id})
+	if err != nil {
+		return fmt.Errorf("purge store: %w", err)
+	}
+	report.Stores = result.DeletedCount
+
+	return nil
+}
+
+// deleteStoreCascade implements DeleteStore with HardDeleteCascade. With WriteModeTransaction,
+// the store and its documents are removed in one transaction, so the deletion is all or nothing.
+// Otherwise the store is soft-deleted first, so that a cascade failing part way leaves a deleted
+// store that PurgeStore, or the purge task, can finish.
+func (ds *Datastore) deleteStoreCascade(ctx context.Context, id string) (*StorePurgeReport, error) {
+	var report *StorePurgeReport
+	deleteStore := func(ctx context.Context) error {
+		// A retried transaction starts over, so it counts from zero again.
+		report = &StorePurgeReport{}
+		if err := ds.softDeleteStore(ctx, id); err != nil {
+			return err
+		}
+		return ds.purgeStoreDocuments(ctx, id, report)
+	}
+
+	var err error
+	if ds.activeWriteMode() == WriteModeTransaction {
+		// Not retried after a commit that gave up, which may have deleted the store already.
+		err = unavailableError(ds.runTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			return deleteStore(sessCtx)
+		}))
+	} else {
+		err = deleteStore(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ds.storeSettingsCache.Delete(id)
+
+	// GridFS files can't join the transaction. They are found by their metadata, so a failure
+	// here leaves files that a later PurgeStore of the same id removes.
+	report.ModelFiles, err = ds.purgeModelFiles(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("purge model files: %w", err)
+	}
+
+	return report, nil
+}
+
+// acquireLease takes or renews the named lease for this instance until now+ttl. It returns false
+// when another instance holds an unexpired lease.
+func (ds *Datastore) acquireLease(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"owner": ds.instanceID},
+			bson.M{"expires_at": bson.M{"$lt": primitive.NewDateTimeFromTime(now)}},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"owner":      ds.instanceID,
+		"expires_at": primitive.NewDateTimeFromTime(now.Add(ttl)),
+	}}
+
+	collection := ds.collection(LeasesCollection)
+	_, err := collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		// The upsert collides with the lease document when another instance holds it.
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("acquire lease %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// startStorePurger starts the background task that purges stores deleted longer than the grace
+// period ago. Close stops it.
+func (ds *Datastore) startStorePurger() {
+	interval := ds.storePurgeInterval
+	if interval <= 0 {
+		interval = defaultStorePurgeInterval
+	}
+
+	ds.runInBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := ds.purgeDeletedStores(ctx, interval); err != nil && ctx.Err() == nil {
+				ds.logger.Error("failed to purge deleted stores", zap.Error(err))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// purgeDeletedStores runs one purge round, provided this instance holds the purge lease. The
+// lease lasts one interval and is renewed before each store, so a round that takes longer than an
+// interval keeps it, and rounds on different instances never overlap. A round that loses the
+// lease, such as after a stall longer than the interval, stops before its next store.
+func (ds *Datastore) purgeDeletedStores(ctx context.Context, interval time.Duration) error {
+	acquired, err := ds.acquireLease(ctx, storePurgeLeaseID, interval)
+	if err != nil || !acquired {
+		return err
+	}
+
+	cutoff := primitive.NewDateTimeFromTime(time.Now().Add(-ds.storePurgeGracePeriod))
+	collection := ds.collection(StoresCollection)
+	cursor, err := collection.Find(ctx, bson.M{"deleted_at": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return fmt.Errorf("find deleted stores: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var errs []error
+	for cursor.Next(ctx) {
+		var doc StoreDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return fmt.Errorf("decode store document: %w", err)
+		}
+
+		acquired, err := ds.acquireLease(ctx, storePurgeLeaseID, interval)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if !acquired {
+			ds.logger.Warn("store purge lease taken over, stopping the purge round")
+			break
+		}
+
+		if _, err := ds.PurgeStore(ctx, doc.ID); err != nil {
+			errs = append(errs, fmt.Errorf("store %s: %w", doc.ID, err))
+			continue
+		}
+		ds.logger.Info("purged deleted store",
+			zap.String("store_id", doc.ID),
+			zap.Time("deleted_at", doc.DeletedAt.Time()),
+		)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor error: %w", err)
+	}
+
+	return errors.Join(errs...)
+}
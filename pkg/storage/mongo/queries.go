@@ -0,0 +1,1113 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// ReadUsers returns the users related to the object by the relation, in ascending order,
+// including typed wildcards ("user:*") and usersets ("group:eng#member"). Only the user field
+// is fetched, and the tuple index makes this a covered query. The returned continuation token
+// is the last user of the page, or empty when there are no more users.
+func (ds *Datastore) ReadUsers(
+	ctx context.Context,
+	store, object, relation string,
+	pagination storage.PaginationOptions,
+) (_ []string, _ string, err error) {
+	ctx, span := ds.startTrace(ctx, "ReadUsers", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, "", err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, "", err
+	}
+	defer releaseStore()
+
+	pageSize := ds.pageSize(pagination.PageSize)
+
+	objectType, objectID := tupleUtils.SplitObject(ds.normalizeObject(object))
+	filter := bson.M{
+		"store":       store,
+		"object_type": objectType,
+		"object_id":   objectID,
+		"relation":    relation,
+	}
+	if pagination.From != "" {
+		filter["user"] = bson.M{"$gt": pagination.From}
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 0, "user": 1}).
+		SetSort(bson.D{{Key: "user", Value: 1}}).
+		SetLimit(int64(pageSize) + 1)
+
+	collection := ds.collection(TuplesCollection)
+	cursor, err := collection.Find(ctx, filter, opts, ds.findTimeout())
+	if err != nil {
+		return nil, "", fmt.Errorf("find users: %w", unavailableError(queryTimeoutError(err)))
+	}
+	defer cursor.Close(ctx)
+
+	var users []string
+	for cursor.Next(ctx) {
+		var doc struct {
+			User string `bson:"user"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, "", fmt.Errorf("decode user: %w", err)
+		}
+		users = append(users, doc.User)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, "", fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	// The extra document only signals that another page exists.
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		setResultCount(span, len(users))
+		return users, users[pageSize-1], nil
+	}
+
+	setResultCount(span, len(users))
+	return users, "", nil
+}
+
+// usersetCostWeight is how many tuple reads a single userset is assumed to expand into when
+// estimating the cost of a Check.
+const usersetCostWeight = 10
+
+// CheckCostEstimate is a rough, first-level estimate of the tuple reads a Check needs.
+type CheckCostEstimate struct {
+	// DirectTuples is the number of tuples on the object and relation whose user is a concrete
+	// user or a typed wildcard.
+	DirectTuples int64
+	// Usersets is the number of tuples on the object and relation whose user is a userset,
+	// each of which needs further expansion.
+	Usersets int64
+	// Score weighs usersets more heavily than direct tuples. It is only meant for comparing
+	// requests against each other or against a threshold.
+	Score int64
+}
+
+// EstimateCheckCost estimates the fan-out of a Check on the tuple key's object and relation by
+// counting the tuples that Check would read at the first level, in a single aggregation. The
+// user of the tuple key is ignored, since fan-out doesn't depend on it. Nested usersets are not
+// followed.
+func (ds *Datastore) EstimateCheckCost(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+) (_ *CheckCostEstimate, err error) {
+	ctx, span := ds.startTrace(ctx, "EstimateCheckCost", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStore()
+
+	objectType, objectID := tupleUtils.SplitObject(ds.normalizeObject(tupleKey.GetObject()))
+
+	isUserset := bson.M{"$gte": bson.A{bson.M{"$indexOfCP": bson.A{"$user", "#"}}, 0}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"store":       store,
+			"object_type": objectType,
+			"object_id":   objectID,
+			"relation":    tupleKey.GetRelation(),
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      nil,
+			"usersets": bson.M{"$sum": bson.M{"$cond": bson.A{isUserset, 1, 0}}},
+			"total":    bson.M{"$sum": 1},
+		}}},
+	}
+
+	collection := ds.collection(TuplesCollection)
+	cursor, err := collection.Aggregate(ctx, pipeline, ds.aggregateTimeout())
+	if err != nil {
+		return nil, fmt.Errorf("aggregate check cost: %w", unavailableError(queryTimeoutError(err)))
+	}
+	defer cursor.Close(ctx)
+
+	estimate := &CheckCostEstimate{}
+	if cursor.Next(ctx) {
+		var doc struct {
+			Usersets int64 `bson:"usersets"`
+			Total    int64 `bson:"total"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode check cost: %w", err)
+		}
+		estimate.Usersets = doc.Usersets
+		estimate.DirectTuples = doc.Total - doc.Usersets
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	estimate.Score = estimate.DirectTuples + estimate.Usersets*usersetCostWeight
+
+	return estimate, nil
+}
+
+// CountObjects returns how many objects of the type the user is directly related to, by any
+// relation, such as the total behind a paginated list of a user's documents. The count is
+// computed by an aggregation on the server, matched on the (store, user, object_type) prefix of
+// the reverse tuple index, so no tuples are sent to the client. Objects the user reaches only
+// through usersets or relation rewrites are not counted. It returns 0 when there are none.
+func (ds *Datastore) CountObjects(ctx context.Context, store, objectType, user string) (_ int64, err error) {
+	ctx, span := ds.startTrace(ctx, "CountObjects", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return 0, err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return 0, err
+	}
+	defer releaseStore()
+
+	// An object related to the user by several relations counts once.
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"store":       store,
+			"user":        ds.normalizeObject(user),
+			"object_type": ds.normalizeType(objectType),
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": "$object_id"}}},
+		{{Key: "$count", Value: "objects"}},
+	}
+
+	var cursor *mongo.Cursor
+	err = ds.retry(ctx, func() (err error) {
+		cursor, err = ds.collection(TuplesCollection).Aggregate(ctx, pipeline, ds.aggregateTimeout())
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("aggregate object count: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	// $count emits no document at all when nothing matched.
+	var result struct {
+		Objects int64 `bson:"objects"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return 0, fmt.Errorf("decode object count: %w", err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	return result.Objects, nil
+}
+
+// CountTuples returns the exact number of the store's tuples that Read returns for the filter,
+// for quotas and billing by tuple count. A nil or empty filter counts every tuple of the store,
+// and an object without an id ("document:") the tuples on every object of the type, of one
+// relation when it is given. The tuples are counted by countDocuments with Read's filter and
+// index, so none is sent to the client, and an empty store counts 0. The count is exact rather
+// than estimated from collection metadata, so it scans the matching index entries.
+func (ds *Datastore) CountTuples(ctx context.Context, store string, filter *openfgav1.TupleKey) (_ int64, err error) {
+	ctx, span := ds.startTrace(ctx, "CountTuples", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return 0, err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return 0, err
+	}
+	defer releaseStore()
+
+	filter = ds.normalizeTupleKey(filter)
+	opts := ds.countTimeout()
+	if hint := hintTupleIndex(options.Find(), filter).Hint; hint != nil {
+		opts.SetHint(hint)
+	}
+
+	var count int64
+	err = ds.retry(ctx, func() (err error) {
+		count, err = ds.collection(TuplesCollection).CountDocuments(ctx, buildTupleFilter(store, filter), opts)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("count tuples: %w", err)
+	}
+	return count, nil
+}
+
+// ReadRelations is like Read for an object, but returns its tuples for any of the given
+// relations, with a single $in query on the relation field instead of one Read per relation.
+// An empty list of relations reads every relation on the object, as Read does for a tuple key
+// without a relation.
+func (ds *Datastore) ReadRelations(
+	ctx context.Context,
+	store, object string,
+	relations []string,
+	readOptions storage.ReadOptions,
+) (_ storage.TupleIterator, err error) {
+	ctx, span := ds.startTrace(ctx, "ReadRelations", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	// The iterator holds the slot until it is stopped.
+	defer func() {
+		if err != nil {
+			releaseStore()
+		}
+	}()
+
+	tupleKey := ds.normalizeTupleKey(&openfgav1.TupleKey{Object: object})
+	filter := buildTupleFilter(store, tupleKey)
+	switch len(relations) {
+	case 0:
+	case 1:
+		filter["relation"] = relations[0]
+	default:
+		filter["relation"] = bson.M{"$in": relations}
+	}
+
+	// The object-leading tuple index serves every relation list, including the empty one.
+	collection := ds.collectionFor(TuplesCollection, readOptions.Consistency)
+	cursor, err := ds.find(ctx, collection, filter, hintTupleIndex(options.Find(), tupleKey))
+	if err != nil {
+		return nil, fmt.Errorf("find tuples: %w", err)
+	}
+
+	return ds.newTupleIterator(ctx, cursor, releaseStore), nil
+}
+
+// MaxUsersPerRead is the maximum number of users accepted by ReadTuplesForUsers.
+const MaxUsersPerRead = 100
+
+// ReadTuplesForUsers returns the tuples on the object whose user is any of the given users,
+// grouped by user, using a single $in query on the user field. At most MaxUsersPerRead users
+// are accepted per call. Results are paginated in ULID order; the continuation token is the
+// ULID of the last returned tuple, or empty when there are no more tuples.
+func (ds *Datastore) ReadTuplesForUsers(
+	ctx context.Context,
+	store string,
+	users []string,
+	object string,
+	pagination storage.PaginationOptions,
+) (_ map[string][]*openfgav1.Tuple, _ string, err error) {
+	ctx, span := ds.startTrace(ctx, "ReadTuplesForUsers", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, "", err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, "", err
+	}
+	defer releaseStore()
+
+	if len(users) > MaxUsersPerRead {
+		return nil, "", fmt.Errorf("%w: got %d, the maximum is %d", ErrTooManyUsers, len(users), MaxUsersPerRead)
+	}
+
+	grouped := make(map[string][]*openfgav1.Tuple, len(users))
+	if len(users) == 0 {
+		return grouped, "", nil
+	}
+
+	pageSize := ds.pageSize(pagination.PageSize)
+
+	objectType, objectID := tupleUtils.SplitObject(ds.normalizeObject(object))
+	filter := bson.M{
+		"store":       store,
+		"object_type": objectType,
+		"object_id":   objectID,
+		"user":        bson.M{"$in": ds.normalizeUsers(users)},
+	}
+	if pagination.From != "" {
+		filter["ulid"] = bson.M{"$gt": pagination.From}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "ulid", Value: 1}}).
+		SetLimit(int64(pageSize) + 1)
+
+	collection := ds.collection(TuplesCollection)
+	cursor, err := collection.Find(ctx, filter, opts, ds.findTimeout())
+	if err != nil {
+		return nil, "", fmt.Errorf("find tuples for users: %w", unavailableError(queryTimeoutError(err)))
+	}
+	defer cursor.Close(ctx)
+
+	var count int
+	var lastULID string
+	for cursor.Next(ctx) {
+		// The extra document only signals that another page exists.
+		if count == pageSize {
+			setResultCount(span, count)
+			return grouped, lastULID, nil
+		}
+
+		var doc TupleDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, "", fmt.Errorf("decode tuple document: %w", err)
+		}
+		grouped[doc.User] = append(grouped[doc.User], docToTuple(&doc))
+		lastULID = doc.ULID
+		count++
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, "", fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	setResultCount(span, count)
+	return grouped, "", nil
+}
+
+// MaxObjectRelationsPerBatchRead is the maximum number of (object, relation) pairs accepted by
+// BatchRead.
+const MaxObjectRelationsPerBatchRead = 100
+
+// BatchRead returns the tuples on each of the given (object, relation) pairs, grouped by the
+// pair as given, formatted as "object#relation". The pairs are read with a single $or query, one
+// branch per pair on the tuple index, so a resolver can prefetch a subtree of a Check in one
+// round-trip instead of a read per relation. Pairs without tuples have no entry. At most
+// MaxObjectRelationsPerBatchRead pairs are accepted per call, and each needs an object of the
+// form type:id and a relation.
+func (ds *Datastore) BatchRead(
+	ctx context.Context,
+	store string,
+	keys []*openfgav1.ObjectRelation,
+	readOptions storage.ReadOptions,
+) (_ map[string][]*openfgav1.Tuple, err error) {
+	ctx, span := ds.startTrace(ctx, "BatchRead", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStore()
+
+	if len(keys) > MaxObjectRelationsPerBatchRead {
+		return nil, fmt.Errorf("%w: got %d, the maximum is %d", ErrTooManyObjectRelations, len(keys), MaxObjectRelationsPerBatchRead)
+	}
+
+	// requested maps each normalized pair to the pairs it was given as, which differ only when
+	// identifier normalization folds several of them together.
+	requested := make(map[string][]string, len(keys))
+	branches := make(bson.A, 0, len(keys))
+	for _, key := range keys {
+		given := tupleUtils.ToObjectRelationString(key.GetObject(), key.GetRelation())
+		objectType, objectID := tupleUtils.SplitObject(ds.normalizeObject(key.GetObject()))
+		if objectType == "" || objectID == "" || key.GetRelation() == "" {
+			return nil, fmt.Errorf("batch read of '%s' needs an object of the form type:id and a relation", given)
+		}
+
+		normalized := tupleUtils.ToObjectRelationString(tupleUtils.BuildObject(objectType, objectID), key.GetRelation())
+		if slices.Contains(requested[normalized], given) {
+			continue
+		}
+		if _, ok := requested[normalized]; !ok {
+			branches = append(branches, bson.M{
+				"object_type": objectType,
+				"object_id":   objectID,
+				"relation":    key.GetRelation(),
+			})
+		}
+		requested[normalized] = append(requested[normalized], given)
+	}
+
+	grouped := make(map[string][]*openfgav1.Tuple, len(requested))
+	if len(branches) == 0 {
+		return grouped, nil
+	}
+
+	collection := ds.collectionFor(TuplesCollection, readOptions.Consistency)
+	cursor, err := ds.find(ctx, collection, bson.M{"store": store, "$or": branches})
+	if err != nil {
+		return nil, fmt.Errorf("find tuples: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var count int
+	for cursor.Next(ctx) {
+		var doc TupleDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode tuple document: %w", err)
+		}
+		tuple := docToTuple(&doc)
+		for _, given := range requested[tupleUtils.ToObjectRelationString(tuple.GetKey().GetObject(), doc.Relation)] {
+			grouped[given] = append(grouped[given], tuple)
+		}
+		count++
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	setResultCount(span, count)
+	return grouped, nil
+}
+
+// ReadFilter selects the tuples of ReadFiltered. Every field is optional, and the fields that are
+// set are combined with AND: a tuple is read only if it matches all of them.
+type ReadFilter struct {
+	// ObjectType matches the objects of the type. It is required with ObjectID or ObjectIDPrefix,
+	// so that every object filter is served by the tuple index.
+	ObjectType string
+	// ObjectID matches the object with the id.
+	ObjectID string
+	// ObjectIDPrefix matches the objects whose id starts with the prefix.
+	ObjectIDPrefix string
+	// Relation matches the relation.
+	Relation string
+	// ExcludedRelation matches every relation but this one.
+	ExcludedRelation string
+	// User matches the user, which may be a typed wildcard or a userset.
+	User string
+	// DirectOnly leaves out tuples whose user is a userset ("group:eng#member"), for reads of
+	// directly assigned subjects. Typed wildcards are kept unless ExcludeWildcards is set too.
+	DirectOnly bool
+	// ExcludeWildcards leaves out tuples whose user is a typed wildcard ("user:*").
+	ExcludeWildcards bool
+}
+
+// ReadFiltered is like Read, but with a filter that can also exclude a relation and match object
+// ids by prefix, such as the tuples of an object but those of one relation. The prefix is matched
+// as a range on object_id, not a regular expression, so the read stays a bounded scan of the
+// tuple index.
+func (ds *Datastore) ReadFiltered(
+	ctx context.Context,
+	store string,
+	filter ReadFilter,
+	readOptions storage.ReadOptions,
+) (_ storage.TupleIterator, err error) {
+	ctx, span := ds.startTrace(ctx, "ReadFiltered", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	if filter.ObjectType == "" && (filter.ObjectID != "" || filter.ObjectIDPrefix != "") {
+		return nil, errors.New("a read filter on object ids needs an object type")
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	// The iterator holds the slot until it is stopped.
+	defer func() {
+		if err != nil {
+			releaseStore()
+		}
+	}()
+
+	collection := ds.collectionFor(TuplesCollection, readOptions.Consistency)
+	query, opts := ds.buildReadFilter(store, filter)
+	cursor, err := ds.find(ctx, collection, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("find tuples: %w", err)
+	}
+
+	return ds.newTupleIterator(ctx, cursor, releaseStore), nil
+}
+
+// buildReadFilter translates a ReadFilter into a query and the index hint serving it. Conditions
+// on the same field share one operator document, which MongoDB applies as an AND.
+func (ds *Datastore) buildReadFilter(store string, filter ReadFilter) (bson.M, *options.FindOptions) {
+	query := bson.M{"store": store}
+	opts := options.Find()
+
+	if filter.ObjectType != "" {
+		query["object_type"] = ds.normalizeType(filter.ObjectType)
+	}
+
+	objectID := bson.M{}
+	if filter.ObjectID != "" {
+		objectID["$eq"] = filter.ObjectID
+	}
+	if filter.ObjectIDPrefix != "" {
+		// Strings compare bytewise, and the UTF-8 encoding of the largest code point sorts after
+		// that of any other, so the range holds every id continuing the prefix.
+		objectID["$gte"] = filter.ObjectIDPrefix
+		objectID["$lt"] = filter.ObjectIDPrefix + string(utf8.MaxRune)
+	}
+	if len(objectID) > 0 {
+		query["object_id"] = objectID
+		opts.SetHint(tupleIndexKeys)
+	}
+
+	relation := bson.M{}
+	if filter.Relation != "" {
+		relation["$eq"] = filter.Relation
+	}
+	if filter.ExcludedRelation != "" {
+		relation["$ne"] = filter.ExcludedRelation
+	}
+	if len(relation) > 0 {
+		query["relation"] = relation
+	}
+
+	if filter.User != "" {
+		query["user"] = ds.normalizeObject(filter.User)
+		if filter.ObjectType == "" {
+			// As in hintTupleIndex, so that the user-leading index serves the read.
+			opts.SetHint(userIndexKeys)
+		}
+	}
+
+	// Only usersets and wildcards have a user_type: "type#relation" for a userset, which never
+	// holds a ':', and "type:*" for a wildcard, which never holds a '#'.
+	switch {
+	case filter.DirectOnly && filter.ExcludeWildcards:
+		query["user_type"] = bson.M{"$exists": false}
+	case filter.DirectOnly:
+		query["user_type"] = bson.M{"$not": primitive.Regex{Pattern: "#"}}
+	case filter.ExcludeWildcards:
+		query["user_type"] = bson.M{"$not": primitive.Regex{Pattern: ":"}}
+	}
+
+	return query, opts
+}
+
+// MaxRelationsPerReadUserTupleRelations is the maximum number of relations accepted by
+// ReadUserTupleRelations.
+const MaxRelationsPerReadUserTupleRelations = 100
+
+// ReadUserTupleRelations is ReadUserTuple for several relations at once: it returns the tuples
+// relating the user to the object by any of the relations, such as the direct branches of a
+// union, with one query instead of a ReadUserTuple per relation. The query matches the store,
+// object and user exactly and the relations with $in, so it reads one entry of the unique tuple
+// index per relation. Tuples are returned in relation order; relations without a tuple are left
+// out. At most MaxRelationsPerReadUserTupleRelations relations are accepted per call.
+func (ds *Datastore) ReadUserTupleRelations(
+	ctx context.Context,
+	store, object, user string,
+	relations []string,
+	readOptions storage.ReadUserTupleOptions,
+) (_ []*openfgav1.Tuple, err error) {
+	ctx, span := ds.startTrace(ctx, "ReadUserTupleRelations", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	if len(relations) > MaxRelationsPerReadUserTupleRelations {
+		return nil, fmt.Errorf("%w: got %d, the maximum is %d", ErrTooManyRelations, len(relations), MaxRelationsPerReadUserTupleRelations)
+	}
+	if len(relations) == 0 {
+		return nil, nil
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStore()
+
+	// As in ReadUserTuple, every other field of the index is matched exactly, even when empty.
+	filter := exactTupleFilter(store, ds.normalizeObject(object), "", ds.normalizeObject(user))
+	filter["relation"] = bson.M{"$in": relations}
+
+	collection := ds.collectionFor(TuplesCollection, readOptions.Consistency)
+	opts := options.Find().
+		SetHint(tupleIndexKeys).
+		SetSort(bson.D{{Key: "relation", Value: 1}})
+	cursor, err := ds.find(ctx, collection, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("find user tuples: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var tuples []*openfgav1.Tuple
+	for cursor.Next(ctx) {
+		var doc TupleDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode tuple document: %w", err)
+		}
+		tuples = append(tuples, docToTuple(&doc))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	setResultCount(span, len(tuples))
+	return tuples, nil
+}
+
+// ReadTupleCondition returns only the condition of the tuple identified by the tuple key: its
+// name and stored context, or nil if the tuple is unconditioned. Only the condition field is
+// fetched, which keeps condition re-evaluation from decoding whole tuple documents. It returns
+// storage.ErrNotFound if the tuple doesn't exist.
+func (ds *Datastore) ReadTupleCondition(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+) (_ *openfgav1.RelationshipCondition, err error) {
+	ctx, span := ds.startTrace(ctx, "ReadTupleCondition", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStore()
+
+	opts := options.FindOne().SetProjection(bson.M{"_id": 0, "condition": 1})
+
+	collection := ds.collection(TuplesCollection)
+	var doc struct {
+		Condition *openfgav1.RelationshipCondition `bson:"condition,omitempty"`
+	}
+	tupleKey = ds.normalizeTupleKey(tupleKey)
+	filter := exactTupleFilter(store, tupleKey.GetObject(), tupleKey.GetRelation(), tupleKey.GetUser())
+	err = collection.FindOne(ctx, filter, opts, ds.findOneTimeout()).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("find tuple condition: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	return doc.Condition, nil
+}
+
+// HasTuple reports whether the tuple key is granted by a stored tuple: the tuple itself or, for a
+// concrete user such as "user:anne", a typed wildcard of the user's type ("user:*") on the same
+// object and relation. It is a single seek of the unique tuple index that fetches no document,
+// for the direct-relation fast path of Check, and returns false rather than storage.ErrNotFound
+// when nothing matches. Conditions aren't evaluated: a conditioned tuple counts as found, so
+// callers that must honor conditions read it with ReadUserTuple or ReadTupleCondition.
+func (ds *Datastore) HasTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey) (_ bool, err error) {
+	ctx, span := ds.startTrace(ctx, "HasTuple", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return false, err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return false, err
+	}
+	defer releaseStore()
+
+	filter := hasTupleFilter(store, ds.normalizeTupleKey(tupleKey))
+	opts := options.FindOne().SetProjection(bson.M{"_id": 1})
+
+	err = ds.retry(ctx, func() error {
+		return ds.collection(TuplesCollection).FindOne(ctx, filter, opts, ds.findOneTimeout()).Err()
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("find tuple: %w", err)
+	}
+	return true, nil
+}
+
+// hasTupleFilter matches the tuple exactly, as ReadUserTuple does, and for a concrete user also
+// the typed wildcard of its type. Usersets and wildcards only match themselves.
+func hasTupleFilter(store string, tupleKey *openfgav1.TupleKey) bson.M {
+	filter := exactTupleFilter(store, tupleKey.GetObject(), tupleKey.GetRelation(), tupleKey.GetUser())
+
+	user := tupleKey.GetUser()
+	if _, relation := tupleUtils.SplitObjectRelation(user); relation == "" && !tupleUtils.IsTypedWildcard(user) {
+		if userType := tupleUtils.GetType(user); userType != "" {
+			filter["user"] = bson.M{"$in": bson.A{user, tupleUtils.TypedPublicWildcard(userType)}}
+		}
+	}
+	return filter
+}
+
+// MaxMembershipGraphDepth is the deepest userset nesting ResolveMembershipGraph follows.
+const MaxMembershipGraphDepth = 2
+
+// MembershipGraph is the flattened result of ResolveMembershipGraph.
+type MembershipGraph struct {
+	// Members are the users and typed wildcards reached, sorted and without duplicates.
+	Members []string
+	// Unresolved are the usersets found at the depth cap that were not expanded. Callers must
+	// resolve them some other way, typically with the regular Check resolver.
+	Unresolved []string
+}
+
+// ResolveMembershipGraph returns the users related to the object by the relation, directly or
+// through up to maxDepth levels of userset tuples ("group:eng#member"). It resolves the whole
+// graph in a single aggregation, using $graphLookup on the object_relation field, instead of one
+// read per level. Only userset tuples are followed: relation rewrites and conditions in the
+// model are not evaluated, so the result is a set of candidates for shallow nested-group checks,
+// not a Check result. maxDepth must be between 0 and MaxMembershipGraphDepth; deeper graphs are
+// reported through Unresolved and should fall back to the resolver.
+func (ds *Datastore) ResolveMembershipGraph(
+	ctx context.Context,
+	store, object, relation string,
+	maxDepth int,
+) (_ *MembershipGraph, err error) {
+	ctx, span := ds.startTrace(ctx, "ResolveMembershipGraph", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStore()
+
+	if maxDepth < 0 || maxDepth > MaxMembershipGraphDepth {
+		return nil, fmt.Errorf("%w: got %d, the maximum is %d", ErrMembershipGraphDepth, maxDepth, MaxMembershipGraphDepth)
+	}
+
+	objectType, objectID := tupleUtils.SplitObject(ds.normalizeObject(object))
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"store":       store,
+			"object_type": objectType,
+			"object_id":   objectID,
+			"relation":    relation,
+		}}},
+	}
+	if maxDepth > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$graphLookup", Value: bson.M{
+			"from":                    ds.collectionName(TuplesCollection),
+			"startWith":               "$user",
+			"connectFromField":        "user",
+			"connectToField":          "object_relation",
+			"as":                      "nested",
+			"maxDepth":                maxDepth - 1,
+			"depthField":              "depth",
+			"restrictSearchWithMatch": bson.M{"store": store},
+		}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$project", Value: bson.M{
+		"_id": 0, "user": 1, "nested.user": 1, "nested.depth": 1,
+	}}})
+
+	collection := ds.collection(TuplesCollection)
+	cursor, err := collection.Aggregate(ctx, pipeline, ds.aggregateTimeout())
+	if err != nil {
+		return nil, fmt.Errorf("aggregate membership graph: %w", unavailableError(queryTimeoutError(err)))
+	}
+	defer cursor.Close(ctx)
+
+	members := map[string]struct{}{}
+	unresolved := map[string]struct{}{}
+	// Usersets at the given depth were expanded by the next level, unless it is the last one.
+	add := func(user string, depth int) {
+		if !tupleUtils.IsObjectRelation(user) {
+			members[user] = struct{}{}
+		} else if depth == maxDepth-1 {
+			unresolved[user] = struct{}{}
+		}
+	}
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			User   string `bson:"user"`
+			Nested []struct {
+				User  string `bson:"user"`
+				Depth int    `bson:"depth"`
+			} `bson:"nested"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode membership graph: %w", err)
+		}
+
+		add(doc.User, -1)
+		for _, nested := range doc.Nested {
+			add(nested.User, nested.Depth)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	setResultCount(span, len(members))
+	return &MembershipGraph{
+		Members:    sortedNames(members),
+		Unresolved: sortedNames(unresolved),
+	}, nil
+}
+
+// ReadObjectTypes returns the distinct object types of the store's tuples, in ascending order.
+// The distinct runs on the tuple index, whose prefix is (store, object_type). The result only
+// reflects the tuples that exist: types declared by the model but without tuples are missing,
+// and types of tuples that no longer match the model are still included. A store without tuples
+// has an empty, non-nil result. Object types are short and few, so the result is never paginated.
+func (ds *Datastore) ReadObjectTypes(ctx context.Context, store string) (_ []string, err error) {
+	ctx, span := ds.startTrace(ctx, "ReadObjectTypes", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseStore()
+
+	collection := ds.collection(TuplesCollection)
+	values, err := collection.Distinct(ctx, "object_type", bson.M{"store": store}, ds.distinctTimeout())
+	if err != nil {
+		return nil, fmt.Errorf("distinct object types: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	objectTypes := make([]string, 0, len(values))
+	for _, value := range values {
+		if objectType, ok := value.(string); ok {
+			objectTypes = append(objectTypes, objectType)
+		}
+	}
+	sort.Strings(objectTypes)
+
+	setResultCount(span, len(objectTypes))
+	return objectTypes, nil
+}
+
+// ReadTuplesModifiedSince returns the store's tuples matching the filter that were written at
+// or after since, oldest first. Tuples are never updated in place, so this is also every tuple
+// modified since then; deletions are only visible in the changelog. Filtering on the object
+// (type and id) is served by the (store, object_type, object_id, inserted_at, ulid) index, and
+// other filters are applied on top of it; without an object the whole store is scanned.
+// The continuation token encodes the write time and ULID of the last returned tuple.
+func (ds *Datastore) ReadTuplesModifiedSince(
+	ctx context.Context,
+	store string,
+	filter *openfgav1.TupleKey,
+	since time.Time,
+	pagination storage.PaginationOptions,
+) (_ []*openfgav1.Tuple, _ string, err error) {
+	ctx, span := ds.startTrace(ctx, "ReadTuplesModifiedSince", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, "", err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, "", err
+	}
+	defer releaseStore()
+
+	pageSize := ds.pageSize(pagination.PageSize)
+
+	mongoFilter := buildTupleFilter(store, ds.normalizeTupleKey(filter))
+	mongoFilter["inserted_at"] = bson.M{"$gte": primitive.NewDateTimeFromTime(since)}
+	if pagination.From != "" {
+		insertedAt, lastULID, err := parseModifiedSinceToken(pagination.From)
+		if err != nil {
+			return nil, "", err
+		}
+		mongoFilter["$or"] = bson.A{
+			bson.M{"inserted_at": bson.M{"$gt": insertedAt}},
+			bson.M{"inserted_at": insertedAt, "ulid": bson.M{"$gt": lastULID}},
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "inserted_at", Value: 1}, {Key: "ulid", Value: 1}}).
+		SetLimit(int64(pageSize) + 1)
+
+	collection := ds.collection(TuplesCollection)
+	cursor, err := collection.Find(ctx, mongoFilter, opts, ds.findTimeout())
+	if err != nil {
+		return nil, "", fmt.Errorf("find modified tuples: %w", unavailableError(queryTimeoutError(err)))
+	}
+	defer cursor.Close(ctx)
+
+	var tuples []*openfgav1.Tuple
+	var last *TupleDocument
+	for cursor.Next(ctx) {
+		// The extra document only signals that another page exists.
+		if len(tuples) == pageSize {
+			setResultCount(span, len(tuples))
+			return tuples, strconv.FormatInt(int64(last.InsertedAt), 10) + ":" + last.ULID, nil
+		}
+
+		var doc TupleDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, "", fmt.Errorf("decode tuple document: %w", err)
+		}
+		tuples = append(tuples, docToTuple(&doc))
+		last = &doc
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, "", fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	setResultCount(span, len(tuples))
+	return tuples, "", nil
+}
+
+// parseModifiedSinceToken splits a ReadTuplesModifiedSince continuation token into the write
+// time and ULID of the last tuple of the previous page.
+func parseModifiedSinceToken(token string) (primitive.DateTime, string, error) {
+	millis, lastULID, ok := strings.Cut(token, ":")
+	if !ok || lastULID == "" {
+		return 0, "", storage.ErrInvalidContinuationToken
+	}
+	insertedAt, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return 0, "", storage.ErrInvalidContinuationToken
+	}
+	return primitive.DateTime(insertedAt), lastULID, nil
+}
+
+// ConditionFilter selects tuples by whether they have a condition.
+type ConditionFilter int
+
+const (
+	// WithoutCondition selects tuples that have no condition.
+	WithoutCondition ConditionFilter = iota
+	// WithCondition selects tuples that have a condition, whatever its name.
+	WithCondition
+)
+
+// ReadTuplesByCondition returns the store's tuples that have, or don't have, a condition, for
+// example to audit grants that bypass conditional access. It is served by the
+// (store, condition.name, ulid) index: tuples without a condition are read in ULID order, and
+// tuples with one in condition name and then ULID order. The continuation token holds the
+// condition name and ULID of the last returned tuple.
+func (ds *Datastore) ReadTuplesByCondition(
+	ctx context.Context,
+	store string,
+	condition ConditionFilter,
+	pagination storage.PaginationOptions,
+) (_ []*openfgav1.Tuple, _ string, err error) {
+	ctx, span := ds.startTrace(ctx, "ReadTuplesByCondition", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, "", err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return nil, "", err
+	}
+	defer releaseStore()
+
+	pageSize := ds.pageSize(pagination.PageSize)
+
+	filter := bson.M{"store": store}
+	if condition == WithCondition {
+		filter["condition.name"] = bson.M{"$type": "string"}
+	} else {
+		filter["condition.name"] = nil
+	}
+
+	if pagination.From != "" {
+		name, lastULID, ok := strings.Cut(pagination.From, ":")
+		if !ok || lastULID == "" {
+			return nil, "", storage.ErrInvalidContinuationToken
+		}
+		if condition == WithCondition {
+			filter["$or"] = bson.A{
+				bson.M{"condition.name": bson.M{"$gt": name}},
+				bson.M{"condition.name": name, "ulid": bson.M{"$gt": lastULID}},
+			}
+		} else {
+			filter["ulid"] = bson.M{"$gt": lastULID}
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "condition.name", Value: 1}, {Key: "ulid", Value: 1}}).
+		SetLimit(int64(pageSize) + 1)
+
+	collection := ds.collection(TuplesCollection)
+	cursor, err := collection.Find(ctx, filter, opts, ds.findTimeout())
+	if err != nil {
+		return nil, "", fmt.Errorf("find tuples by condition: %w", unavailableError(queryTimeoutError(err)))
+	}
+	defer cursor.Close(ctx)
+
+	var tuples []*openfgav1.Tuple
+	var last *TupleDocument
+	for cursor.Next(ctx) {
+		// The extra document only signals that another page exists.
+		if len(tuples) == pageSize {
+			setResultCount(span, len(tuples))
+			return tuples, last.Condition.GetName() + ":" + last.ULID, nil
+		}
+
+		var doc TupleDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, "", fmt.Errorf("decode tuple document: %w", err)
+		}
+		tuples = append(tuples, docToTuple(&doc))
+		last = &doc
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, "", fmt.Errorf("cursor error: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	setResultCount(span, len(tuples))
+	return tuples, "", nil
+}
@@ -0,0 +1,87 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"go.uber.org/zap"
+)
+
+const (
+	// retryableWriteErrorLabel marks a write error the server or driver considers safe to retry.
+	retryableWriteErrorLabel = "RetryableWriteError"
+	defaultMaxRetries        = 3
+	defaultRetryBaseDelay    = 50 * time.Millisecond
+)
+
+// isRetryableError reports whether err is a transient failure, such as a primary stepdown or a
+// dropped connection, after which the same operation can succeed.
+func isRetryableError(err error) bool {
+	return hasErrorLabel(err, transientTransactionErrorLabel) ||
+		hasErrorLabel(err, retryableWriteErrorLabel) ||
+		mongo.IsNetworkError(err)
+}
+
+// unavailableError returns err wrapped in ErrUnavailable when MongoDB couldn't be reached,
+// because server selection failed or the connection did, and err unchanged otherwise. A request
+// canceled by its caller is left alone.
+func unavailableError(err error) error {
+	if err == nil || errors.Is(err, ErrUnavailable) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	if errors.As(err, &topology.ServerSelectionError{}) || mongo.IsNetworkError(err) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
+
+// retry runs op, and runs it again with exponential backoff while it fails with a retryable
+// error, up to MaxRetries more times. Waiting stops when ctx is done, so retries never outlive
+// the request. op must be safe to repeat: a read or an idempotent write. A transaction is not,
+// since its commit may have been applied when it fails with an unknown result.
+func (ds *Datastore) retry(ctx context.Context, op func() error) error {
+	maxRetries := ds.maxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	policy := backoff.NewExponentialBackOff()
+	policy.InitialInterval = ds.retryBaseDelay
+	if policy.InitialInterval <= 0 {
+		policy.InitialInterval = defaultRetryBaseDelay
+	}
+	// The number of retries and the context bound the retries, not the elapsed time.
+	policy.MaxElapsedTime = 0
+
+	err := backoff.RetryNotify(func() error {
+		err := op()
+		if err != nil && (ctx.Err() != nil || !isRetryableError(err)) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(backoff.WithMaxRetries(policy, uint64(maxRetries)), ctx), func(err error, wait time.Duration) {
+		ds.logger.Warn("retrying mongodb operation", zap.Duration("backoff", wait), zap.Error(err))
+	})
+	return unavailableError(queryTimeoutError(err))
+}
+
+// find runs a Find with retries and the query timeout. Only opening the cursor is retried; a
+// failure while iterating it is returned to the caller.
+func (ds *Datastore) find(
+	ctx context.Context,
+	collection *mongo.Collection,
+	filter interface{},
+	opts ...*options.FindOptions,
+) (*mongo.Cursor, error) {
+	var cursor *mongo.Cursor
+	err := ds.retry(ctx, func() (err error) {
+		cursor, err = collection.Find(ctx, filter, append(opts, ds.findTimeout())...)
+		return err
+	})
+	return cursor, err
+}
@@ -0,0 +1,117 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// ExpectedSchemaVersion is the document schema version this code reads and writes. Each version
+// is reached by a step of Migrate:
+//
+//	1: objects are stored as object_type and object_id rather than a combined object field
+//	2: userset and wildcard tuples have a user_type field
+const ExpectedSchemaVersion = 2
+
+// schemaMetaID is the _id of the schema_meta document recording the database's schema version.
+const schemaMetaID = "schema"
+
+// SchemaMetaDocument records the schema version a database was last migrated to.
+type SchemaMetaDocument struct {
+	ID        string             `bson:"_id"`
+	Version   int                `bson:"version"`
+	UpdatedAt primitive.DateTime `bson:"updated_at"`
+}
+
+// schemaVersion returns the database's recorded schema version, and whether one was recorded.
+func (ds *Datastore) schemaVersion(ctx context.Context) (int, bool, error) {
+	var doc SchemaMetaDocument
+	err := ds.retry(ctx, func() error {
+		return ds.collection(SchemaMetaCollection).FindOne(ctx, bson.M{"_id": schemaMetaID}, ds.findOneTimeout()).Decode(&doc)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("read schema version: %w", err)
+	}
+	return doc.Version, true, nil
+}
+
+// recordSchemaVersion records that the database is at ExpectedSchemaVersion. A greater version,
+// recorded by newer code, is kept.
+func (ds *Datastore) recordSchemaVersion(ctx context.Context) error {
+	_, err := ds.writeCollection(SchemaMetaCollection).UpdateOne(ctx,
+		bson.M{"_id": schemaMetaID},
+		bson.M{
+			"$max": bson.M{"version": ExpectedSchemaVersion},
+			"$set": bson.M{"updated_at": primitive.NewDateTimeFromTime(time.Now())},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("record schema version: %w", writeConcernError(err))
+	}
+	return nil
+}
+
+// checkSchemaVersion refuses to open a database whose schema is older than ExpectedSchemaVersion,
+// whose documents would otherwise be read as if they had been migrated, or only warns about it
+// with AllowOutdatedSchema. A database without a recorded version is at version 0, unless it has
+// no tuples or changes yet, in which case there is nothing to migrate and the expected version is
+// recorded.
+func (ds *Datastore) checkSchemaVersion(ctx context.Context) error {
+	version, recorded, err := ds.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !recorded {
+		empty, err := ds.hasNoTuples(ctx)
+		if err != nil {
+			return err
+		}
+		if empty {
+			return ds.recordSchemaVersion(ctx)
+		}
+	}
+
+	switch {
+	case version < ExpectedSchemaVersion && ds.allowOutdatedSchema:
+		ds.logger.Warn("mongodb schema is older than this version expects, run the migrate command",
+			zap.Int("schema_version", version),
+			zap.Int("expected_schema_version", ExpectedSchemaVersion))
+	case version < ExpectedSchemaVersion:
+		return fmt.Errorf("%w: database is at version %d, expected %d", ErrSchemaOutdated, version, ExpectedSchemaVersion)
+	case version > ExpectedSchemaVersion:
+		ds.logger.Warn("mongodb schema is newer than this version expects",
+			zap.Int("schema_version", version),
+			zap.Int("expected_schema_version", ExpectedSchemaVersion))
+	}
+	return nil
+}
+
+// hasNoTuples reports whether the tuples and changelog collections are both empty.
+func (ds *Datastore) hasNoTuples(ctx context.Context) (bool, error) {
+	for _, name := range []string{TuplesCollection, ChangelogCollection} {
+		var count int64
+		err := ds.retry(ctx, func() (err error) {
+			count, err = ds.collection(name).CountDocuments(ctx, bson.M{}, options.Count().SetLimit(1), ds.countTimeout())
+			return err
+		})
+		if err != nil {
+			return false, fmt.Errorf("count %s: %w", name, err)
+		}
+		if count > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
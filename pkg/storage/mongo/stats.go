@@ -0,0 +1,75 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// StoreStats counts a store's documents, and the documents of every store together, for
+// dashboards that watch tuple growth.
+type StoreStats struct {
+	Tuples              int64 `json:"tuples"`
+	AuthorizationModels int64 `json:"authorization_models"`
+	// Assertions counts the store's assertion sets, one per model that has assertions.
+	Assertions int64 `json:"assertions"`
+
+	// The totals cover every store, deleted stores not yet purged included.
+	TotalTuples              int64 `json:"total_tuples"`
+	TotalAuthorizationModels int64 `json:"total_authorization_models"`
+	TotalAssertions          int64 `json:"total_assertions"`
+
+	// SchemaVersion is the database's recorded schema version, and ExpectedSchemaVersion the one
+	// this code expects; they differ until the migrate command has run.
+	SchemaVersion         int `json:"schema_version"`
+	ExpectedSchemaVersion int `json:"expected_schema_version"`
+}
+
+// Stats returns the number of tuples, authorization models and assertion sets of the store, and
+// of all stores. The store's counts are always exact, counted on the index led by store. The
+// totals are estimated from the collections' metadata, which is cheap but may be off after an
+// unclean shutdown; with exact they are counted instead, which reads every index entry. An
+// unknown store has zero counts. The stats also report the database's schema version.
+func (ds *Datastore) Stats(ctx context.Context, store string, exact bool) (_ *StoreStats, err error) {
+	ctx, span := ds.startTrace(ctx, "Stats", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	stats := &StoreStats{}
+	for _, count := range []struct {
+		collection   string
+		store, total *int64
+	}{
+		{TuplesCollection, &stats.Tuples, &stats.TotalTuples},
+		{AuthorizationModelsCollection, &stats.AuthorizationModels, &stats.TotalAuthorizationModels},
+		{AssertionsCollection, &stats.Assertions, &stats.TotalAssertions},
+	} {
+		collection := ds.collection(count.collection)
+		err := ds.retry(ctx, func() (err error) {
+			*count.store, err = collection.CountDocuments(ctx, bson.M{"store": store}, ds.countTimeout())
+			if err != nil {
+				return err
+			}
+			if exact {
+				*count.total, err = collection.CountDocuments(ctx, bson.M{}, ds.countTimeout())
+			} else {
+				*count.total, err = collection.EstimatedDocumentCount(ctx)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("count %s: %w", count.collection, err)
+		}
+	}
+
+	stats.ExpectedSchemaVersion = ExpectedSchemaVersion
+	if stats.SchemaVersion, _, err = ds.schemaVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
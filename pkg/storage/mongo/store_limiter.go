@@ -0,0 +1,34 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// storeSlotKey marks a context whose operation already holds a slot of the store named by its
+// value, so that the datastore methods it calls internally don't take another.
+type storeSlotKey struct{}
+
+// acquireStoreSlot takes one of the store's MaxConcurrentPerStore slots for an operation and
+// returns the context to run it with and the function that releases the slot. Unlike
+// acquireWriteSlot it doesn't wait: a store with every slot taken fails the operation with
+// ErrStoreBusy straight away, so that a noisy store's callers back off rather than queue up and
+// hold the connection pool.
+func (ds *Datastore) acquireStoreSlot(ctx context.Context, store string) (context.Context, func(), error) {
+	if ds.maxConcurrentPerStore <= 0 {
+		return ctx, func() {}, nil
+	}
+	if held, _ := ctx.Value(storeSlotKey{}).(string); held == store {
+		return ctx, func() {}, nil
+	}
+
+	limiter, _ := ds.storeLimiters.LoadOrStore(store, semaphore.NewWeighted(int64(ds.maxConcurrentPerStore)))
+	slots := limiter.(*semaphore.Weighted)
+	if !slots.TryAcquire(1) {
+		return ctx, nil, fmt.Errorf("%w: %s has %d operations in flight", ErrStoreBusy, store, ds.maxConcurrentPerStore)
+	}
+
+	return context.WithValue(ctx, storeSlotKey{}, store), func() { slots.Release(1) }, nil
+}
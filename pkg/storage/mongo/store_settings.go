@@ -0,0 +1,115 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultStoreSettingsCacheTTL bounds how long a settings change made by another
+// instance can take to become visible.
+const defaultStoreSettingsCacheTTL = 10 * time.Second
+
+// StoreSettings holds per-store overrides of datastore behaviors. A nil field means
+// the store uses the datastore-wide configuration for that behavior.
+type StoreSettings struct {
+	Store                 string             `bson:"store"`
+	StrictTupleValidation *bool              `bson:"strict_tuple_validation,omitempty"`
+	UpdatedAt             primitive.DateTime `bson:"updated_at"`
+	// ChangelogVacuumedBefore is the cutoff of the store's last VacuumChangelog. It is recorded
+	// by the vacuum and kept by UpdateStoreSettings.
+	ChangelogVacuumedBefore *primitive.DateTime `bson:"changelog_vacuumed_before,omitempty"`
+}
+
+// CacheEntityType implements [storage.CacheItem].
+func (s *StoreSettings) CacheEntityType() string {
+	return "mongo_store_settings"
+}
+
+// GetStoreSettings returns the settings document for the store. If none was ever
+// written, it returns settings with every field unset.
+func (ds *Datastore) GetStoreSettings(ctx context.Context, store string) (_ *StoreSettings, err error) {
+	ctx, span := ds.startTrace(ctx, "GetStoreSettings", storeAttributes(store, StoreSettingsCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	if ds.storeSettingsCache != nil {
+		if cached := ds.storeSettingsCache.Get(store); cached != nil {
+			return cached, nil
+		}
+	}
+
+	collection := ds.collection(StoreSettingsCollection)
+
+	settings := &StoreSettings{Store: store}
+	err = collection.FindOne(ctx, bson.M{"store": store}, ds.findOneTimeout()).Decode(settings)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("find store settings: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	if ds.storeSettingsCache != nil {
+		ds.storeSettingsCache.Set(store, settings, ds.storeSettingsCacheTTL)
+	}
+
+	return settings, nil
+}
+
+// UpdateStoreSettings replaces the store's overrides with those of settings. The change takes
+// effect immediately on this instance and within the settings cache TTL on others.
+func (ds *Datastore) UpdateStoreSettings(ctx context.Context, store string, settings *StoreSettings) (_ *StoreSettings, err error) {
+	ctx, span := ds.startTrace(ctx, "UpdateStoreSettings", storeAttributes(store, StoreSettingsCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	collection := ds.collection(StoreSettingsCollection)
+
+	doc := &StoreSettings{
+		Store:                 store,
+		StrictTupleValidation: settings.StrictTupleValidation,
+		UpdatedAt:             primitive.NewDateTimeFromTime(time.Now()),
+	}
+
+	// The overridable fields are replaced, but the vacuum cutoff isn't one of them.
+	set := bson.M{"updated_at": doc.UpdatedAt}
+	update := bson.M{"$set": set}
+	if doc.StrictTupleValidation != nil {
+		set["strict_tuple_validation"] = *doc.StrictTupleValidation
+	} else {
+		update["$unset"] = bson.M{"strict_tuple_validation": ""}
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := collection.FindOneAndUpdate(ctx, bson.M{"store": store}, update, opts).Decode(doc); err != nil {
+		return nil, fmt.Errorf("update store settings: %w", err)
+	}
+
+	if ds.storeSettingsCache != nil {
+		ds.storeSettingsCache.Delete(store)
+	}
+
+	return doc, nil
+}
+
+// strictTupleValidationFor resolves whether strict tuple validation applies to the store.
+func (ds *Datastore) strictTupleValidationFor(ctx context.Context, store string) (bool, error) {
+	settings, err := ds.GetStoreSettings(ctx, store)
+	if err != nil {
+		return false, err
+	}
+	if settings.StrictTupleValidation != nil {
+		return *settings.StrictTupleValidation, nil
+	}
+	return ds.strictTupleValidation, nil
+}
@@ -0,0 +1,150 @@
+package mongo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+const (
+	storeSlugIndexName = "slug_1"
+	// maxStoreSlugLength bounds the slug derived from the name, before any collision suffix.
+	maxStoreSlugLength = 48
+	// maxStoreSlugAttempts is how many suffixed slugs are tried after the plain one is taken.
+	maxStoreSlugAttempts = 5
+)
+
+// errSlugTaken signals that a store insert collided on the slug index rather than the store ID.
+var errSlugTaken = errors.New("store slug already taken")
+
+// isDuplicateKeyOnIndex reports whether err is a duplicate key error raised by the named index,
+// from an insert or update, or from a findAndModify, which reports it as a command error.
+func isDuplicateKeyOnIndex(err error, index string) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return mongo.IsDuplicateKeyError(cmdErr) && strings.Contains(cmdErr.Message, "index: "+index+" ")
+	}
+	var writeErr mongo.WriteException
+	if !errors.As(err, &writeErr) {
+		return false
+	}
+	for _, we := range writeErr.WriteErrors {
+		if mongo.IsDuplicateKeyError(we) && strings.Contains(we.Message, "index: "+index+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// slugify turns a store name into a lowercase, URL-safe slug: runs of anything other than
+// ASCII letters and digits become a single dash, and leading and trailing dashes are dropped.
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= maxStoreSlugLength {
+			break
+		}
+	}
+
+	slug := strings.Trim(b.String(), "-")
+	if slug == "" {
+		return "store"
+	}
+	return slug
+}
+
+// slugSuffix returns a short random suffix used to resolve slug collisions.
+func slugSuffix() (string, error) {
+	buf := make([]byte, 3)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate slug suffix: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// CreateStoreWithSlug creates the store like CreateStore and also gives it a unique slug derived
+// from its name. If the slug is already taken, a short random suffix is appended. It returns
+// the created store and its final slug. Slugs of deleted stores stay reserved.
+func (ds *Datastore) CreateStoreWithSlug(ctx context.Context, store *openfgav1.Store) (_ *openfgav1.Store, _ string, err error) {
+	ctx, span := ds.startTrace(ctx, "CreateStoreWithSlug", storeAttributes(store.GetId(), StoresCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, "", err
+	}
+
+	return ds.createStoreWithSlug(ctx, store)
+}
+
+func (ds *Datastore) createStoreWithSlug(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, string, error) {
+	base := slugify(store.GetName())
+
+	slug := base
+	for attempt := 0; ; attempt++ {
+		created, err := ds.insertStore(ctx, store, slug)
+		if err == nil {
+			return created, slug, nil
+		}
+		if !errors.Is(err, errSlugTaken) {
+			return nil, "", err
+		}
+		if attempt == maxStoreSlugAttempts {
+			return nil, "", fmt.Errorf("no free slug for store name '%s': %w", store.GetName(), storage.ErrCollision)
+		}
+
+		suffix, err := slugSuffix()
+		if err != nil {
+			return nil, "", err
+		}
+		slug = base + "-" + suffix
+	}
+}
+
+// GetStoreBySlug returns the store with the given slug. It returns storage.ErrNotFound if no
+// such store exists or it has been deleted.
+func (ds *Datastore) GetStoreBySlug(ctx context.Context, slug string) (_ *openfgav1.Store, err error) {
+	ctx, span := ds.startTrace(ctx, "GetStoreBySlug", attribute.String(collectionAttribute, StoresCollection))
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	collection := ds.collection(StoresCollection)
+
+	var doc StoreDocument
+	err = collection.FindOne(ctx, bson.M{"slug": slug, "deleted_at": bson.M{"$exists": false}}, ds.findOneTimeout()).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("find store by slug: %w", unavailableError(queryTimeoutError(err)))
+	}
+
+	return &openfgav1.Store{
+		Id:        doc.ID,
+		Name:      doc.Name,
+		CreatedAt: timestamppb.New(doc.CreatedAt.Time()),
+		UpdatedAt: timestamppb.New(doc.UpdatedAt.Time()),
+	}, nil
+}
@@ -0,0 +1,72 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The query timeout is sent to the server as maxTimeMS, so a runaway query is stopped there
+// rather than only abandoned by the client. It is independent of the caller's context: the
+// client gives up when the context is done, the server when the query has run for QueryTimeout,
+// whichever comes first. Zero leaves queries without a server-side limit.
+
+// findTimeout returns options applying the query timeout to a Find.
+func (ds *Datastore) findTimeout() *options.FindOptions {
+	opts := options.Find()
+	if ds.queryTimeout > 0 {
+		opts.SetMaxTime(ds.queryTimeout)
+	}
+	return opts
+}
+
+// findOneTimeout returns options applying the query timeout to a FindOne.
+func (ds *Datastore) findOneTimeout() *options.FindOneOptions {
+	opts := options.FindOne()
+	if ds.queryTimeout > 0 {
+		opts.SetMaxTime(ds.queryTimeout)
+	}
+	return opts
+}
+
+// aggregateTimeout returns options applying the query timeout to an Aggregate.
+func (ds *Datastore) aggregateTimeout() *options.AggregateOptions {
+	opts := options.Aggregate()
+	if ds.queryTimeout > 0 {
+		opts.SetMaxTime(ds.queryTimeout)
+	}
+	return opts
+}
+
+// countTimeout returns options applying the query timeout to a CountDocuments.
+func (ds *Datastore) countTimeout() *options.CountOptions {
+	opts := options.Count()
+	if ds.queryTimeout > 0 {
+		opts.SetMaxTime(ds.queryTimeout)
+	}
+	return opts
+}
+
+// distinctTimeout returns options applying the query timeout to a Distinct.
+func (ds *Datastore) distinctTimeout() *options.DistinctOptions {
+	opts := options.Distinct()
+	if ds.queryTimeout > 0 {
+		opts.SetMaxTime(ds.queryTimeout)
+	}
+	return opts
+}
+
+// queryTimeoutError returns err wrapped in ErrQueryTimeout when the server stopped the query
+// for exceeding its maxTimeMS, and err unchanged otherwise.
+func queryTimeoutError(err error) error {
+	if errors.Is(err, ErrQueryTimeout) {
+		return err
+	}
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.IsMaxTimeMSExpiredError() {
+		return fmt.Errorf("%w: %w", ErrQueryTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package mongo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/openfga/openfga/pkg/encoder"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+const (
+	// continuationTokenVersion prefixes every encoded continuation token.
+	continuationTokenVersion = "v1"
+	// continuationTokenChecksumSize is the number of SHA-256 bytes kept as the token checksum.
+	continuationTokenChecksumSize = 8
+	// changesTokenVersion is the version of the ChangesToken this code writes, and the newest it
+	// reads.
+	changesTokenVersion = 1
+)
+
+// continuationTokenChecksum returns the checksum of a token's version and payload.
+func continuationTokenChecksum(payload string) []byte {
+	sum := sha256.Sum256([]byte(continuationTokenVersion + "." + payload))
+	return sum[:continuationTokenChecksumSize]
+}
+
+// EncodeContinuationToken wraps a continuation token returned by the datastore in an opaque,
+// versioned and checksummed form suitable for handing to clients. The empty token, which means
+// there are no more results, is returned as is.
+func EncodeContinuationToken(token string) string {
+	if token == "" {
+		return ""
+	}
+
+	encoding := base64.RawURLEncoding
+	return continuationTokenVersion + "." +
+		encoding.EncodeToString([]byte(token)) + "." +
+		encoding.EncodeToString(continuationTokenChecksum(token))
+}
+
+// DecodeContinuationToken reverses EncodeContinuationToken, returning the datastore token.
+// Malformed tokens, tokens of another version and tokens whose checksum doesn't match yield an
+// error wrapping storage.ErrInvalidContinuationToken. The checksum catches corrupted or edited
+// tokens, but it is not a signature: it doesn't stop a client from forging a new token.
+func DecodeContinuationToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w: malformed token", storage.ErrInvalidContinuationToken)
+	}
+	if parts[0] != continuationTokenVersion {
+		return "", fmt.Errorf("%w: unsupported version '%s'", storage.ErrInvalidContinuationToken, parts[0])
+	}
+
+	encoding := base64.RawURLEncoding
+	payload, err := encoding.DecodeString(parts[1])
+	if err != nil || len(payload) == 0 {
+		return "", fmt.Errorf("%w: malformed payload", storage.ErrInvalidContinuationToken)
+	}
+	checksum, err := encoding.DecodeString(parts[2])
+	if err != nil || !bytes.Equal(checksum, continuationTokenChecksum(string(payload))) {
+		return "", fmt.Errorf("%w: checksum mismatch", storage.ErrInvalidContinuationToken)
+	}
+
+	return string(payload), nil
+}
+
+// ValidateContinuationToken reports whether the token is an empty or well-formed encoded
+// continuation token, without querying the database.
+func ValidateContinuationToken(token string) error {
+	_, err := DecodeContinuationToken(token)
+	return err
+}
+
+// validateULIDToken checks a continuation token passed to ReadPage or ReadChanges. Those methods
+// page by ULID, and the server hands them decoded tokens, so the token must be a ULID.
+func validateULIDToken(token string) error {
+	if _, err := ulid.ParseStrict(token); err != nil {
+		return fmt.Errorf("%w: %s", storage.ErrInvalidContinuationToken, err)
+	}
+	return nil
+}
+
+// ChangesToken is the content of the ReadChanges continuation tokens the server hands to clients,
+// before the server base64-encodes it. Its JSON has the fields of the SQL datastores' tokens,
+// {"ulid": ..., "ObjectType": ...}, with the same meaning: the ULID of the last change returned
+// and the object type filter of the read. Tokens therefore stay valid when a store moves between
+// a SQL-backed server and this one, in either direction.
+type ChangesToken struct {
+	ULID       string `json:"ulid"`
+	ObjectType string `json:"ObjectType"`
+	// Version is the token's format version. The SQL datastores' tokens have none, which reads as
+	// version 1. Fields added by later versions are ignored by older readers, and tokens of a
+	// version newer than this code knows are rejected.
+	Version int `json:"v,omitempty"`
+}
+
+// ContinuationTokenSerializer serializes ReadChanges continuation tokens as a ChangesToken.
+type ContinuationTokenSerializer struct{}
+
+var _ encoder.ContinuationTokenSerializer = (*ContinuationTokenSerializer)(nil)
+
+// NewContinuationTokenSerializer returns the serializer to use for ReadChanges continuation tokens
+// with this datastore.
+func NewContinuationTokenSerializer() encoder.ContinuationTokenSerializer {
+	return &ContinuationTokenSerializer{}
+}
+
+// Serialize implements [encoder.ContinuationTokenSerializer].
+func (s *ContinuationTokenSerializer) Serialize(ulid string, objType string) ([]byte, error) {
+	if ulid == "" {
+		return nil, errors.New("empty ulid provided for continuation token")
+	}
+	return json.Marshal(ChangesToken{ULID: ulid, ObjectType: objType, Version: changesTokenVersion})
+}
+
+// Deserialize implements [encoder.ContinuationTokenSerializer]. Tokens that aren't a ChangesToken,
+// whose version is newer than this code knows or whose ULID is malformed yield an error wrapping
+// storage.ErrInvalidContinuationToken.
+func (s *ContinuationTokenSerializer) Deserialize(continuationToken string) (ulid string, objType string, err error) {
+	var token ChangesToken
+	if err := json.Unmarshal([]byte(continuationToken), &token); err != nil {
+		return "", "", fmt.Errorf("%w: %s", storage.ErrInvalidContinuationToken, err)
+	}
+	if token.Version > changesTokenVersion {
+		return "", "", fmt.Errorf("%w: unsupported version %d", storage.ErrInvalidContinuationToken, token.Version)
+	}
+	if err := validateULIDToken(token.ULID); err != nil {
+		return "", "", err
+	}
+	return token.ULID, token.ObjectType, nil
+}
@@ -0,0 +1,76 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// tracerName is the instrumentation name of the datastore's spans.
+const tracerName = "openfga/pkg/storage/mongo"
+
+// tracer is used by datastores configured without a TracerProvider.
+var tracer = otel.Tracer(tracerName)
+
+// Span attribute keys.
+const (
+	storeIDAttribute     = "store_id"
+	collectionAttribute  = "db.collection.name"
+	resultCountAttribute = "result_count"
+)
+
+// operationSpan is the span of a datastore operation. Ending it also records the operation's
+// duration in the datastore's metrics.
+type operationSpan struct {
+	trace.Span
+	method  string
+	start   time.Time
+	metrics *datastoreMetrics
+}
+
+// End see [trace.Span].End.
+func (s *operationSpan) End(options ...trace.SpanEndOption) {
+	s.metrics.observeDuration(s.method, s.start)
+	s.Span.End(options...)
+}
+
+// startTrace starts the span of a datastore operation, named "mongo.<name>".
+func (ds *Datastore) startTrace(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *operationSpan) {
+	t := ds.tracer
+	if t == nil {
+		t = tracer
+	}
+	ctx, span := t.Start(ctx, "mongo."+name, trace.WithAttributes(attrs...))
+	return ctx, &operationSpan{Span: span, method: name, start: time.Now(), metrics: ds.metrics}
+}
+
+// storeAttributes describes an operation on the given store and collection.
+func storeAttributes(store, collection string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String(storeIDAttribute, store),
+		attribute.String(collectionAttribute, collection),
+	}
+}
+
+// setResultCount records how many results an operation returned.
+func setResultCount(span trace.Span, count int) {
+	span.SetAttributes(attribute.Int(resultCountAttribute, count))
+}
+
+// endTrace records the outcome of an operation, in its span and in the error metrics, and ends
+// the span. Not finding anything is an expected outcome, not an error.
+func endTrace(span *operationSpan, err error) {
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.metrics.countError(span.method, err)
+	}
+	span.End()
+}
@@ -0,0 +1,221 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.uber.org/zap"
+)
+
+const (
+	// Error labels attached by the server or driver to errors inside a transaction.
+	transientTransactionErrorLabel  = "TransientTransactionError"
+	unknownCommitResultErrorLabel   = "UnknownTransactionCommitResult"
+	defaultMaxCommitRetries         = 5
+	defaultCommitRetryTimeout       = 30 * time.Second
+	defaultTransactionRetryDeadline = 120 * time.Second
+)
+
+// hasErrorLabel reports whether err carries the given server or driver error label.
+func hasErrorLabel(err error, label string) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorLabel(label)
+}
+
+// runTransaction runs fn inside a transaction, following the driver's recommended pattern:
+// a TransientTransactionError retries the whole transaction, while an
+// UnknownTransactionCommitResult only retries the commit. Commit retries have their own
+// budget (maxCommitRetries within commitRetryTimeout), separate from transaction retries.
+func (ds *Datastore) runTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := ds.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	deadline := time.Now().Add(defaultTransactionRetryDeadline)
+
+	return mongo.WithSession(ctx, session, func(sessCtx mongo.SessionContext) error {
+		for {
+			// Transactions must read from the primary, whatever the configured read preference.
+			// The driver ignores the write concern of operations inside the transaction, so it is
+			// set on the transaction itself.
+			txnOptions := options.Transaction().SetReadPreference(readpref.Primary())
+			if ds.writeConcern != nil {
+				txnOptions.SetWriteConcern(ds.writeConcern)
+			}
+			if err := session.StartTransaction(txnOptions); err != nil {
+				return fmt.Errorf("start transaction: %w", err)
+			}
+
+			if err := fn(sessCtx); err != nil {
+				_ = session.AbortTransaction(sessCtx)
+				if hasErrorLabel(err, transientTransactionErrorLabel) && time.Now().Before(deadline) && ctx.Err() == nil {
+					continue
+				}
+				return err
+			}
+
+			err := ds.commitWithRetry(sessCtx, session)
+			if err != nil && hasErrorLabel(err, transientTransactionErrorLabel) && time.Now().Before(deadline) && ctx.Err() == nil {
+				continue
+			}
+			return err
+		}
+	})
+}
+
+// commitWithRetry commits the session's transaction, retrying only the commit while the
+// outcome is unknown.
+func (ds *Datastore) commitWithRetry(sessCtx mongo.SessionContext, session mongo.Session) error {
+	maxRetries := ds.maxCommitRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxCommitRetries
+	}
+	timeout := ds.commitRetryTimeout
+	if timeout <= 0 {
+		timeout = defaultCommitRetryTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for attempt := 1; ; attempt++ {
+		err := session.CommitTransaction(sessCtx)
+		if err == nil {
+			return nil
+		}
+
+		if !hasErrorLabel(err, unknownCommitResultErrorLabel) ||
+			attempt > maxRetries ||
+			time.Now().After(deadline) ||
+			sessCtx.Err() != nil {
+			return fmt.Errorf("commit transaction: %w", err)
+		}
+
+		ds.metrics.commitRetried()
+		ds.logger.Warn("retrying mongodb transaction commit", zap.Int("attempt", attempt), zap.Error(err))
+	}
+}
+
+// detectTransactionSupport asks the server, at startup, whether the deployment supports
+// transactions, and caches the answer for SupportsTransactions. Datastores created by New keep it
+// up to date from the driver's topology events afterwards.
+func (ds *Datastore) detectTransactionSupport() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	standalone, err := ds.isStandalone(ctx)
+	if err != nil {
+		return err
+	}
+	ds.supportsTransactions.Store(!standalone)
+	return nil
+}
+
+// SupportsTransactions reports whether the deployment is a replica set or sharded cluster, which
+// support transactions and change streams, rather than a standalone server.
+func (ds *Datastore) SupportsTransactions() bool {
+	return ds.supportsTransactions.Load()
+}
+
+// defaultWriteMode picks the write mode used when none is configured: transactions when the
+// deployment supports them, and intents on a standalone server, which does not. The fallback is
+// logged once, at startup, since writes are then no longer atomic.
+func (ds *Datastore) defaultWriteMode() string {
+	if ds.SupportsTransactions() {
+		return WriteModeTransaction
+	}
+
+	ds.logger.Warn("mongodb is a standalone server without transaction support, writes will not be atomic",
+		zap.String("write_mode", WriteModeIntent))
+	return WriteModeIntent
+}
+
+// activeWriteMode returns the write mode a write uses now. A configured WriteMode is always used;
+// without one, the mode follows the deployment's current transaction support.
+func (ds *Datastore) activeWriteMode() string {
+	if !ds.autoWriteMode {
+		return ds.writeMode
+	}
+	if ds.SupportsTransactions() {
+		return WriteModeTransaction
+	}
+	return WriteModeIntent
+}
+
+// topologyMonitor re-detects transaction support when the driver reports a topology change, such
+// as a standalone server restarted as a replica set member. Events before the datastore is
+// attached are ignored; the datastore detects the support itself at startup.
+type topologyMonitor struct {
+	datastore atomic.Pointer[Datastore]
+}
+
+// serverMonitor returns the driver monitor that feeds topology changes to the datastore.
+func (m *topologyMonitor) serverMonitor() *event.ServerMonitor {
+	return &event.ServerMonitor{
+		TopologyDescriptionChanged: func(e *event.TopologyDescriptionChangedEvent) {
+			ds := m.datastore.Load()
+			if ds == nil {
+				return
+			}
+			supported, known := topologySupportsTransactions(e.NewDescription)
+			if !known || ds.supportsTransactions.Swap(supported) == supported {
+				return
+			}
+			ds.logger.Info("mongodb transaction support changed",
+				zap.Bool("supports_transactions", supported),
+				zap.String("topology", e.NewDescription.Kind.String()))
+		},
+	}
+}
+
+// topologySupportsTransactions reports whether a topology supports transactions, and whether that
+// is known yet: a single server is only known once the driver has reached it.
+func topologySupportsTransactions(topology description.Topology) (supported, known bool) {
+	switch topology.Kind {
+	case description.ReplicaSet, description.ReplicaSetNoPrimary, description.ReplicaSetWithPrimary,
+		description.Sharded, description.LoadBalanced:
+		return true, true
+	case description.Single:
+		// A direct connection to a replica set member is a single topology too.
+		for _, server := range topology.Servers {
+			switch server.Kind {
+			case description.Standalone:
+				return false, true
+			case description.Unknown:
+			default:
+				return true, true
+			}
+		}
+	}
+	return false, false
+}
+
+// isStandalone reports whether the server is neither a replica set member nor a mongos.
+func (ds *Datastore) isStandalone(ctx context.Context) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+
+	admin := ds.client.Database("admin")
+	err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		// Servers before 4.4.2 only know the legacy name of the command.
+		err = admin.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello)
+	}
+	if err != nil {
+		return false, fmt.Errorf("detect mongodb topology: %w", err)
+	}
+
+	return hello.SetName == "" && hello.Msg != "isdbgrid", nil
+}
@@ -0,0 +1,228 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.uber.org/zap"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// changelogRetentionIndexName names the TTL index installed for ChangelogRetention, so that it is
+// told apart from any other index on the changelog timestamp.
+const changelogRetentionIndexName = "changelog_retention"
+
+// VacuumChangelog permanently deletes the store's changelog entries older than olderThan and
+// returns how many were removed. Unlike PruneChangelog it is scoped to one store and records the
+// cutoff in the store's settings first, so that ReadChanges fails a continuation token from
+// before it with storage.ErrInvalidContinuationToken rather than silently skipping the vacuumed
+// changes. The cutoff only ever moves forward. Like PruneChangelog the delete uses the
+// ChangelogPruneWriteConcern.
+func (ds *Datastore) VacuumChangelog(ctx context.Context, store string, olderThan time.Duration) (_ int64, err error) {
+	ctx, span := ds.startTrace(ctx, "VacuumChangelog", storeAttributes(store, ChangelogCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return 0, err
+	}
+	if store == "" {
+		return 0, fmt.Errorf("store id is required: %w", storage.ErrInvalidWriteInput)
+	}
+	if olderThan < 0 {
+		return 0, fmt.Errorf("vacuum age must not be negative, got %s: %w", olderThan, storage.ErrInvalidWriteInput)
+	}
+
+	cutoff := primitive.NewDateTimeFromTime(time.Now().Add(-olderThan))
+
+	_, err = ds.writeCollection(StoreSettingsCollection).UpdateOne(ctx,
+		bson.M{"store": store},
+		bson.M{"$max": bson.M{"changelog_vacuumed_before": cutoff}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("record changelog vacuum cutoff: %w", writeConcernError(err))
+	}
+	if ds.storeSettingsCache != nil {
+		ds.storeSettingsCache.Delete(store)
+	}
+
+	wc := ds.changelogPruneWriteConcern
+	if wc == nil {
+		wc = writeconcern.W1()
+	}
+	collection := ds.collection(ChangelogCollection, options.Collection().SetWriteConcern(wc))
+
+	result, err := collection.DeleteMany(ctx, bson.M{"store": store, "timestamp": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, fmt.Errorf("vacuum changelog: %w", unavailableError(err))
+	}
+
+	setResultCount(span, int(result.DeletedCount))
+	return result.DeletedCount, nil
+}
+
+// checkChangesToken returns the changelog position to read changes after, for a ReadChanges or
+// ChangesSince token. A continuation token fails with storage.ErrInvalidContinuationToken when
+// changes after it may be gone: it is from before the store's changelog was vacuumed, or from
+// before the ChangelogRetention window and its own change has expired. A start time, which the
+// server passes as a ULID without entropy, is no continuation token: one from before the cutoff
+// is moved up to it, so that the changes that remain are returned.
+func (ds *Datastore) checkChangesToken(ctx context.Context, store, token string) (string, error) {
+	var retained time.Time
+	if ds.changelogRetention > 0 {
+		retained = time.Now().Add(-ds.changelogRetention)
+	}
+
+	settings, err := ds.GetStoreSettings(ctx, store)
+	if err != nil {
+		return "", err
+	}
+	var vacuumed time.Time
+	if settings.ChangelogVacuumedBefore != nil {
+		vacuumed = settings.ChangelogVacuumedBefore.Time()
+	}
+
+	if isStartTimeToken(token) {
+		cutoff := vacuumed
+		if retained.After(cutoff) {
+			cutoff = retained
+		}
+		if !tokenBefore(token, cutoff) {
+			return token, nil
+		}
+		clamped, err := ulid.New(ulid.Timestamp(cutoff), nil)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", storage.ErrInvalidStartTime, err)
+		}
+		return clamped.String(), nil
+	}
+
+	if tokenBefore(token, vacuumed) {
+		return "", fmt.Errorf("%w: changes after it were vacuumed", storage.ErrInvalidContinuationToken)
+	}
+	if tokenBefore(token, retained) {
+		// The TTL monitor removes changes oldest first, so those after the token's change are
+		// still there as long as it is.
+		err := ds.collection(ChangelogCollection).FindOne(ctx, bson.M{"store": store, "ulid": token},
+			ds.findOneTimeout().SetProjection(bson.M{"_id": 1})).Err()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", fmt.Errorf("%w: changes after it expired", storage.ErrInvalidContinuationToken)
+		}
+		if err != nil {
+			return "", fmt.Errorf("find change of continuation token: %w", unavailableError(queryTimeoutError(err)))
+		}
+	}
+	return token, nil
+}
+
+// isStartTimeToken reports whether the ULID token has no entropy, as the ULIDs the server makes
+// from a ReadChanges start time do, unlike those of changes.
+func isStartTimeToken(token string) bool {
+	id, err := ulid.Parse(token)
+	if err != nil {
+		return false
+	}
+	for _, b := range id.Entropy() {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenBefore reports whether the ULID token was made before cutoff. A zero cutoff is never
+// reached.
+func tokenBefore(token string, cutoff time.Time) bool {
+	if cutoff.IsZero() {
+		return false
+	}
+	id, err := ulid.Parse(token)
+	if err != nil {
+		return false
+	}
+	return ulid.Time(id.Time()).Before(cutoff)
+}
+
+// ensureChangelogRetention installs the TTL index on the changelog timestamp that enforces
+// ChangelogRetention, changes its expiry when the retention was changed, and drops it when the
+// retention was unset. It reports whether the index already existed with the right expiry.
+func (ds *Datastore) ensureChangelogRetention(ctx context.Context) (bool, error) {
+	expiry, err := ds.changelogRetentionExpiry(ctx)
+	if err != nil {
+		return false, err
+	}
+	indexes := ds.collection(ChangelogCollection).Indexes()
+	seconds := int32(ds.changelogRetention / time.Second)
+
+	switch {
+	case ds.changelogRetention <= 0 && expiry == nil:
+		return true, nil
+	case ds.changelogRetention <= 0:
+		ds.logger.Info("dropping mongodb changelog retention index")
+		if _, err := indexes.DropOne(ctx, changelogRetentionIndexName); err != nil {
+			return false, fmt.Errorf("drop changelog retention index: %w", err)
+		}
+		return false, nil
+	case expiry != nil && *expiry == seconds:
+		return true, nil
+	case expiry != nil:
+		ds.logger.Info("changing mongodb changelog retention",
+			zap.Duration("from", time.Duration(*expiry)*time.Second),
+			zap.Duration("to", ds.changelogRetention))
+		err := ds.database.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: ds.collectionName(ChangelogCollection)},
+			{Key: "index", Value: bson.D{
+				{Key: "name", Value: changelogRetentionIndexName},
+				{Key: "expireAfterSeconds", Value: seconds},
+			}},
+		}).Err()
+		if err != nil {
+			return false, fmt.Errorf("change changelog retention: %w", err)
+		}
+		return false, nil
+	}
+
+	err = ds.ensureIndex(ctx, ChangelogCollection, mongo.IndexModel{
+		Keys: bson.D{{Key: "timestamp", Value: 1}},
+		Options: options.Index().
+			SetName(changelogRetentionIndexName).
+			SetExpireAfterSeconds(seconds).
+			SetBackground(!ds.foregroundIndexBuilds),
+	})
+	if err != nil {
+		return false, fmt.Errorf("create changelog retention index: %w", err)
+	}
+	return false, nil
+}
+
+// changelogRetentionExpiry returns the expiry of the changelog retention index, or nil when the
+// index doesn't exist.
+func (ds *Datastore) changelogRetentionExpiry(ctx context.Context) (*int32, error) {
+	cursor, err := ds.collection(ChangelogCollection).Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list changelog indexes: %w", err)
+	}
+	var existing []struct {
+		Name               string `bson:"name"`
+		ExpireAfterSeconds *int32 `bson:"expireAfterSeconds"`
+	}
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, fmt.Errorf("decode changelog indexes: %w", err)
+	}
+
+	for _, index := range existing {
+		if index.Name == changelogRetentionIndexName {
+			return index.ExpireAfterSeconds, nil
+		}
+	}
+	return nil, nil
+}
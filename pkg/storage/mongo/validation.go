@@ -0,0 +1,216 @@
+package mongo
+
+import (
+	"fmt"
+	"sync"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/condition/types"
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// allowedTriples is the set of (object type, relation, user type, condition) combinations
+// that a model allows to be written directly.
+type allowedTriples map[string]struct{}
+
+// allowedTripleKey builds the lookup key for a single combination. The user type is either a
+// plain type ("user"), a typed wildcard ("user:*") or a userset type ("group#member").
+func allowedTripleKey(objectType, relation, userType, condition string) string {
+	return objectType + "#" + relation + "@" + userType + "|" + condition
+}
+
+// newAllowedTriples builds the allowed set from the model's directly_related_user_types metadata.
+func newAllowedTriples(model *openfgav1.AuthorizationModel) allowedTriples {
+	allowed := allowedTriples{}
+	for _, typeDef := range model.GetTypeDefinitions() {
+		for relation, metadata := range typeDef.GetMetadata().GetRelations() {
+			for _, ref := range metadata.GetDirectlyRelatedUserTypes() {
+				userType := ref.GetType()
+				switch {
+				case ref.GetWildcard() != nil:
+					userType = tupleUtils.TypedPublicWildcard(ref.GetType())
+				case ref.GetRelation() != "":
+					userType = ref.GetType() + "#" + ref.GetRelation()
+				}
+				allowed[allowedTripleKey(typeDef.GetType(), relation, userType, ref.GetCondition())] = struct{}{}
+			}
+		}
+	}
+	return allowed
+}
+
+// allows reports whether the tuple matches one of the allowed combinations.
+func (a allowedTriples) allows(tk *openfgav1.TupleKey) bool {
+	objectType := tupleUtils.GetType(tk.GetObject())
+
+	userObjectType, _, userRelation := tupleUtils.ToUserParts(tk.GetUser())
+	userType := userObjectType
+	switch {
+	case tupleUtils.IsTypedWildcard(tk.GetUser()):
+		userType = tupleUtils.TypedPublicWildcard(userObjectType)
+	case userRelation != "":
+		userType = userObjectType + "#" + userRelation
+	}
+
+	_, ok := a[allowedTripleKey(objectType, tk.GetRelation(), userType, tk.GetCondition().GetName())]
+	return ok
+}
+
+// validateTupleKeys checks the shape of every tuple to write, independently of any model: the
+// object is "type:id", the relation is a plain name, and the user is "type:id", "type:*" or
+// "type:id#relation". Untyped user ids ("anne") and "*" from schema 1.0 models are still
+// accepted, as the storage contract requires. It returns an error describing the first
+// malformed tuple.
+func validateTupleKeys(writes storage.Writes) error {
+	for _, tk := range writes {
+		var problem string
+		switch object, user := tk.GetObject(), tk.GetUser(); {
+		case !tupleUtils.IsValidObject(object) || tupleUtils.IsTypedWildcard(object):
+			problem = fmt.Sprintf("object '%s' must have the form 'type:id'", object)
+		case !tupleUtils.IsValidRelation(tk.GetRelation()):
+			problem = fmt.Sprintf("relation '%s' must be a non-empty name without ':', '#', '@' or spaces", tk.GetRelation())
+		case !tupleUtils.IsValidUser(user):
+			problem = fmt.Sprintf("user '%s' must have the form 'type:id', 'type:*' or 'type:id#relation'", user)
+		default:
+			continue
+		}
+		return fmt.Errorf("%w: %s in tuple '%s'", ErrInvalidTuple, problem, tupleUtils.TupleKeyToString(tk))
+	}
+	return nil
+}
+
+// validateTupleLengths checks that no tuple to write has an object longer than MaxObjectLength
+// bytes or a user longer than MaxUserLength bytes. It returns an error naming the first tuple
+// that does.
+func (ds *Datastore) validateTupleLengths(writes storage.Writes) error {
+	maxObjectLength, maxUserLength := ds.tupleLengthLimits()
+	for _, tk := range writes {
+		var problem string
+		switch {
+		case len(tk.GetObject()) > maxObjectLength:
+			problem = fmt.Sprintf("object is %d bytes, at most %d allowed", len(tk.GetObject()), maxObjectLength)
+		case len(tk.GetUser()) > maxUserLength:
+			problem = fmt.Sprintf("user is %d bytes, at most %d allowed", len(tk.GetUser()), maxUserLength)
+		default:
+			continue
+		}
+		return fmt.Errorf("%w: %s in tuple '%s'", ErrTupleTooLong, problem, tupleUtils.TupleKeyToString(tk))
+	}
+	return nil
+}
+
+// modelValidator caches the allowed triples and condition parameter types per authorization model. Models are
+// immutable once written, so entries never need to be invalidated.
+type modelValidator struct {
+	cache      sync.Map // model id -> allowedTriples
+	conditions sync.Map // model id -> conditionSchemas
+}
+
+func (v *modelValidator) allowedFor(model *openfgav1.AuthorizationModel) allowedTriples {
+	if cached, ok := v.cache.Load(model.GetId()); ok {
+		return cached.(allowedTriples)
+	}
+	allowed := newAllowedTriples(model)
+	v.cache.Store(model.GetId(), allowed)
+	return allowed
+}
+
+// validate checks every write against the model and returns an error describing the first
+// tuple that is not an allowed direct relationship.
+func (v *modelValidator) validate(model *openfgav1.AuthorizationModel, writes storage.Writes) error {
+	allowed := v.allowedFor(model)
+	for _, tk := range writes {
+		if !allowed.allows(tk) {
+			return fmt.Errorf(
+				"%w: model '%s' does not allow user '%s' as a direct '%s' of '%s'",
+				ErrTupleNotAllowedByModel,
+				model.GetId(),
+				tk.GetUser(),
+				tk.GetRelation(),
+				tk.GetObject(),
+			)
+		}
+	}
+	return nil
+}
+
+// conditionSchemas holds the decoded parameter types of each condition in a model, by condition
+// name and then parameter name.
+type conditionSchemas map[string]map[string]*types.ParameterType
+
+// newConditionSchemas decodes the parameter types of every condition in the model.
+func newConditionSchemas(model *openfgav1.AuthorizationModel) (conditionSchemas, error) {
+	schemas := make(conditionSchemas, len(model.GetConditions()))
+	for name, condition := range model.GetConditions() {
+		params := make(map[string]*types.ParameterType, len(condition.GetParameters()))
+		for param, typeRef := range condition.GetParameters() {
+			paramType, err := types.DecodeParameterType(typeRef)
+			if err != nil {
+				return nil, fmt.Errorf("decode parameter '%s' of condition '%s': %w", param, name, err)
+			}
+			params[param] = paramType
+		}
+		schemas[name] = params
+	}
+	return schemas, nil
+}
+
+func (v *modelValidator) conditionsFor(model *openfgav1.AuthorizationModel) (conditionSchemas, error) {
+	if cached, ok := v.conditions.Load(model.GetId()); ok {
+		return cached.(conditionSchemas), nil
+	}
+	schemas, err := newConditionSchemas(model)
+	if err != nil {
+		return nil, err
+	}
+	v.conditions.Store(model.GetId(), schemas)
+	return schemas, nil
+}
+
+// hasConditionContext reports whether any of the writes carries a condition context.
+func hasConditionContext(writes storage.Writes) bool {
+	for _, tk := range writes {
+		if len(tk.GetCondition().GetContext().GetFields()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// validateContexts checks the condition context of every write against the parameters of its
+// condition and returns an error naming the first offending key.
+func (v *modelValidator) validateContexts(model *openfgav1.AuthorizationModel, writes storage.Writes) error {
+	schemas, err := v.conditionsFor(model)
+	if err != nil {
+		return err
+	}
+
+	for _, tk := range writes {
+		fields := tk.GetCondition().GetContext().GetFields()
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := tk.GetCondition().GetName()
+		params, ok := schemas[name]
+		if !ok {
+			return fmt.Errorf("%w: model '%s' does not define condition '%s'", ErrConditionContextMismatch, model.GetId(), name)
+		}
+
+		for key, value := range fields {
+			paramType, ok := params[key]
+			if !ok {
+				return fmt.Errorf("%w: condition '%s' has no parameter '%s'", ErrConditionContextMismatch, name, key)
+			}
+			if _, err := paramType.ConvertValue(value.AsInterface()); err != nil {
+				return fmt.Errorf(
+					"%w: parameter '%s' of condition '%s' must be a %s: %v",
+					ErrConditionContextMismatch, key, name, paramType, err,
+				)
+			}
+		}
+	}
+	return nil
+}
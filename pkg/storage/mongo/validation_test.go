@@ -0,0 +1,175 @@
+package mongo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestValidateWritesAgainstModel(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [user, user:*, group#member, user with in_office]
+				define owner: [user]
+		condition in_office(ip: ipaddress) {
+			ip.in_cidr("10.0.0.0/8")
+		}`)
+
+	ds := &Datastore{}
+
+	tests := []struct {
+		name    string
+		tuple   *openfgav1.TupleKey
+		allowed bool
+	}{
+		{"direct_user", tuple.NewTupleKey("document:1", "viewer", "user:anne"), true},
+		{"wildcard", tuple.NewTupleKey("document:1", "viewer", "user:*"), true},
+		{"userset", tuple.NewTupleKey("document:1", "viewer", "group:eng#member"), true},
+		{"conditioned", tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:anne", "in_office", nil), true},
+		{"wildcard_not_allowed", tuple.NewTupleKey("document:1", "owner", "user:*"), false},
+		{"userset_not_allowed", tuple.NewTupleKey("document:1", "owner", "group:eng#member"), false},
+		{"wrong_user_type", tuple.NewTupleKey("group:eng", "member", "group:other#member"), false},
+		{"undefined_relation", tuple.NewTupleKey("document:1", "editor", "user:anne"), false},
+		{"undefined_object_type", tuple.NewTupleKey("folder:1", "viewer", "user:anne"), false},
+		{"unknown_condition", tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:anne", "other", nil), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ds.ValidateWritesAgainstModel(model, []*openfgav1.TupleKey{test.tuple})
+			if test.allowed {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrTupleNotAllowedByModel)
+			}
+		})
+	}
+
+	_, cached := ds.modelValidator.cache.Load(model.GetId())
+	require.True(t, cached)
+}
+
+func TestValidateConditionContexts(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user with in_office]
+		condition in_office(ip: ipaddress, max_hour: int) {
+			ip.in_cidr("10.0.0.0/8")
+		}`)
+
+	ds := &Datastore{}
+
+	withContext := func(name string, context map[string]any) *openfgav1.TupleKey {
+		ctx, err := structpb.NewStruct(context)
+		require.NoError(t, err)
+		return tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:anne", name, ctx)
+	}
+
+	tests := []struct {
+		name  string
+		tuple *openfgav1.TupleKey
+		err   string
+	}{
+		{"no_context", tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:anne", "in_office", nil), ""},
+		{"declared_params", withContext("in_office", map[string]any{"ip": "10.0.0.1", "max_hour": 18}), ""},
+		{"unknown_key", withContext("in_office", map[string]any{"room": "b12"}), "no parameter 'room'"},
+		{"wrong_type", withContext("in_office", map[string]any{"max_hour": "late"}), "parameter 'max_hour'"},
+		{"unknown_condition", withContext("other", map[string]any{"ip": "10.0.0.1"}), "does not define condition 'other'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ds.ValidateConditionContexts(model, []*openfgav1.TupleKey{test.tuple})
+			if test.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrConditionContextMismatch)
+				require.ErrorContains(t, err, test.err)
+			}
+		})
+	}
+
+	_, cached := ds.modelValidator.conditions.Load(model.GetId())
+	require.True(t, cached)
+}
+
+func TestValidateTupleKeys(t *testing.T) {
+	valid := []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:doc1", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:doc1", "viewer", "user:*"),
+		tuple.NewTupleKey("document:doc1", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("document:doc1", "viewer", "anne"),
+		tuple.NewTupleKey("document:doc1", "viewer", "*"),
+	}
+	require.NoError(t, validateTupleKeys(valid))
+
+	for name, tk := range map[string]*openfgav1.TupleKey{
+		"empty_object":       tuple.NewTupleKey("", "viewer", "user:anne"),
+		"untyped_object":     tuple.NewTupleKey("doc1", "viewer", "user:anne"),
+		"wildcard_object":    tuple.NewTupleKey("document:*", "viewer", "user:anne"),
+		"empty_object_id":    tuple.NewTupleKey("document:", "viewer", "user:anne"),
+		"empty_relation":     tuple.NewTupleKey("document:doc1", "", "user:anne"),
+		"malformed_relation": tuple.NewTupleKey("document:doc1", "view#er", "user:anne"),
+		"empty_user":         tuple.NewTupleKey("document:doc1", "viewer", ""),
+		"user_with_space":    tuple.NewTupleKey("document:doc1", "viewer", "user:anne smith"),
+		"userset_wildcard":   tuple.NewTupleKey("document:doc1", "viewer", "group:*#member"),
+		"empty_userset":      tuple.NewTupleKey("document:doc1", "viewer", "group:eng#"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := validateTupleKeys(append(valid, tk))
+			require.ErrorIs(t, err, ErrInvalidTuple)
+			require.ErrorIs(t, err, storage.ErrInvalidWriteInput)
+		})
+	}
+}
+
+func TestValidateTupleLengths(t *testing.T) {
+	// Objects and users of exactly the limit, "document:" and "user:" included.
+	object := "document:" + strings.Repeat("o", 16-len("document:"))
+	user := "user:" + strings.Repeat("u", 8-len("user:"))
+	atLimit := storage.Writes{tuple.NewTupleKey(object, "viewer", user)}
+	ds := &Datastore{maxObjectLength: 16, maxUserLength: 8}
+	require.NoError(t, ds.validateTupleLengths(atLimit))
+
+	for name, tk := range map[string]*openfgav1.TupleKey{
+		"object_over_limit":  tuple.NewTupleKey(object+"o", "viewer", user),
+		"user_over_limit":    tuple.NewTupleKey(object, "viewer", user+"u"),
+		"userset_over_limit": tuple.NewTupleKey(object, "viewer", "group:g#member"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := ds.validateTupleLengths(append(atLimit, tk))
+			require.ErrorIs(t, err, ErrTupleTooLong)
+			require.ErrorIs(t, err, ErrInvalidTuple)
+			require.ErrorIs(t, err, storage.ErrInvalidWriteInput)
+			require.Contains(t, err.Error(), tuple.TupleKeyToString(tk))
+		})
+	}
+
+	// Unset limits are the API's.
+	ds = &Datastore{}
+	require.NoError(t, ds.validateTupleLengths(storage.Writes{
+		tuple.NewTupleKey("document:"+strings.Repeat("o", DefaultMaxObjectLength-len("document:")), "viewer", "user:anne"),
+	}))
+	require.ErrorIs(t, ds.validateTupleLengths(storage.Writes{
+		tuple.NewTupleKey("document:doc1", "viewer", "user:"+strings.Repeat("u", DefaultMaxUserLength)),
+	}), ErrTupleTooLong)
+}
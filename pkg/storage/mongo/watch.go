@@ -0,0 +1,133 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+// Server error codes of change streams that can't be opened or resumed.
+const (
+	mongoChangeStreamNotSupportedCode = 40573
+	mongoInvalidResumeTokenCode       = 260
+	mongoChangeStreamFatalErrorCode   = 280
+	mongoChangeStreamHistoryLostCode  = 286
+)
+
+// WatchEvent is a tuple change delivered by Watch.
+type WatchEvent struct {
+	Change *openfgav1.TupleChange
+	// ResumeToken, passed to a later Watch, resumes the stream right after this change.
+	ResumeToken bson.Raw
+	// Err is set on the last event, without a change, when the stream stopped before its context
+	// was done.
+	Err error
+}
+
+// Watch streams the store's tuple changes as they are committed, for consumers such as caches
+// that must see changes made by other instances without polling ReadChanges. It opens a change
+// stream on the changelog, which records the whole tuple of writes and deletes alike, and only
+// delivers confirmed entries: a change written in WriteModeIntent appears once it is confirmed.
+// With a resumeToken from an earlier event the stream resumes right after it; nil starts with
+// the changes committed from now on.
+//
+// Change streams need a replica set or sharded cluster; on a standalone server Watch fails with
+// ErrChangeStreamsUnavailable. A token the server can't resume from, such as one older than the
+// oplog, fails with ErrInvalidResumeToken, either from Watch or as the Err of the last event.
+// The channel is closed when ctx is done, when the datastore is closed, or after an event with
+// an error.
+func (ds *Datastore) Watch(ctx context.Context, store string, resumeToken bson.Raw) (_ <-chan WatchEvent, err error) {
+	ctx, span := ds.startTrace(ctx, "Watch", storeAttributes(store, ChangelogCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	if !ds.SupportsTransactions() {
+		return nil, fmt.Errorf("%w: the server is a standalone", ErrChangeStreamsUnavailable)
+	}
+
+	// Intent mode inserts its entries as pending and confirms them by removing the field.
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"fullDocument.store":   store,
+		"fullDocument.pending": bson.M{"$ne": true},
+		"$or": bson.A{
+			bson.M{"operationType": "insert"},
+			bson.M{"operationType": "update", "updateDescription.removedFields": "pending"},
+		},
+	}}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeToken != nil {
+		opts.SetResumeAfter(resumeToken)
+	}
+
+	stream, err := ds.collection(ChangelogCollection).Watch(ctx, pipeline, opts)
+	if err != nil {
+		return nil, fmt.Errorf("open change stream: %w", changeStreamError(err))
+	}
+
+	events := make(chan WatchEvent)
+	ds.runInBackground(func(rootCtx context.Context) {
+		defer close(events)
+
+		// The caller's context ends the stream, and so does Close through the root context.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stopOnClose := context.AfterFunc(rootCtx, cancel)
+		defer stopOnClose()
+		defer stream.Close(context.WithoutCancel(ctx))
+
+		for stream.Next(ctx) {
+			var event struct {
+				FullDocument ChangelogDocument `bson:"fullDocument"`
+			}
+			if err := stream.Decode(&event); err != nil {
+				sendWatchEvent(ctx, events, WatchEvent{Err: fmt.Errorf("decode change event: %w", err)})
+				return
+			}
+			if !sendWatchEvent(ctx, events, WatchEvent{Change: event.FullDocument.toTupleChange(), ResumeToken: stream.ResumeToken()}) {
+				return
+			}
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			sendWatchEvent(ctx, events, WatchEvent{Err: fmt.Errorf("change stream: %w", changeStreamError(err))})
+		}
+	})
+
+	return events, nil
+}
+
+// sendWatchEvent delivers the event unless ctx is done first, and reports whether it was delivered.
+func sendWatchEvent(ctx context.Context, events chan<- WatchEvent, event WatchEvent) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// changeStreamError wraps the server's refusal to open a change stream in
+// ErrChangeStreamsUnavailable, and its refusal to resume one in ErrInvalidResumeToken.
+func changeStreamError(err error) error {
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return unavailableError(err)
+	}
+	switch {
+	case serverErr.HasErrorCode(mongoChangeStreamNotSupportedCode):
+		return fmt.Errorf("%w: %w", ErrChangeStreamsUnavailable, err)
+	case serverErr.HasErrorCode(mongoInvalidResumeTokenCode),
+		serverErr.HasErrorCode(mongoChangeStreamFatalErrorCode),
+		serverErr.HasErrorCode(mongoChangeStreamHistoryLostCode):
+		return fmt.Errorf("%w: %w", ErrInvalidResumeToken, err)
+	}
+	return err
+}
@@ -0,0 +1,336 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// WriteBatchOptions configures WriteBatch.
+type WriteBatchOptions struct {
+	// Atomic applies the batch as Write does, all of it or none: a batch with any failed tuple is
+	// rolled back, or not started, and the error reports the offending tuples. Otherwise the
+	// batch's tuples are applied in an unordered bulk write outside any transaction, so that the
+	// valid ones are committed and only the failed ones are reported.
+	Atomic bool
+}
+
+// WriteFailure is a tuple of a WriteBatch that wasn't applied, with the reason.
+type WriteFailure struct {
+	TupleKey  *openfgav1.TupleKey
+	Operation openfgav1.TupleOperation
+	Err       error
+}
+
+// WriteBatchError is returned by WriteBatch when tuples of the batch failed. It unwraps to the
+// error of each failure, so that errors.Is finds storage.ErrInvalidWriteInput, ErrInvalidTuple or
+// storage.ErrCollision among them.
+type WriteBatchError struct {
+	Failures []WriteFailure
+	// Applied is the number of deletes and writes that were committed, always zero for an atomic
+	// batch.
+	Applied int
+}
+
+func (e *WriteBatchError) Error() string {
+	if len(e.Failures) == 0 {
+		return fmt.Sprintf("write batch failed, %d changes applied", e.Applied)
+	}
+	return fmt.Sprintf("%d tuples of the write batch failed, %d changes applied, first error: %v",
+		len(e.Failures), e.Applied, e.Failures[0].Err)
+}
+
+func (e *WriteBatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		errs = append(errs, failure.Err)
+	}
+	return errs
+}
+
+// tupleError attributes an error of a Write to the tuple that caused it, so that WriteBatch can
+// report the tuple. It reads and unwraps as the error it carries, so Write's errors are unchanged.
+type tupleError struct {
+	tupleKey  *openfgav1.TupleKey
+	operation openfgav1.TupleOperation
+	err       error
+}
+
+func (e *tupleError) Error() string { return e.err.Error() }
+
+func (e *tupleError) Unwrap() error { return e.err }
+
+// WriteBatch is like Write, but reports every tuple of the batch that failed, in a
+// WriteBatchError, rather than the first one. With opts.Atomic it is Write: nothing is applied
+// when any tuple fails, in WriteModeTransaction by rolling the transaction back. Without it the
+// tuples that are malformed, not allowed by the model, already stored or repeated in the batch,
+// and the deletes of tuples that don't exist, fail on their own while the rest of the batch is
+// committed, whatever the write mode. The changelog of such a batch is kept as WriteModeIntent
+// keeps it, so an interrupted batch is settled by ReconcileChangelog.
+func (ds *Datastore) WriteBatch(
+	ctx context.Context,
+	store string,
+	deletes storage.Deletes,
+	writes storage.Writes,
+	opts WriteBatchOptions,
+) (err error) {
+	ctx, span := ds.startTrace(ctx, "WriteBatch", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return err
+	}
+
+	ctx, releaseStore, err := ds.acquireStoreSlot(ctx, store)
+	if err != nil {
+		return err
+	}
+	defer releaseStore()
+
+	deletes, writes = ds.normalizeWrites(deletes, writes)
+	if store == "" {
+		return fmt.Errorf("store id is required: %w", storage.ErrInvalidWriteInput)
+	}
+	if len(deletes) == 0 && len(writes) == 0 {
+		if ds.rejectEmptyWrites {
+			return fmt.Errorf("no writes or deletes provided: %w", storage.ErrInvalidWriteInput)
+		}
+		return nil
+	}
+	if len(deletes)+len(writes) > ds.MaxTuplesPerWrite() {
+		return fmt.Errorf("%w: %d tuples, at most %d allowed",
+			storage.ErrExceededWriteBatchLimit, len(deletes)+len(writes), ds.MaxTuplesPerWrite())
+	}
+
+	// Each write is validated on its own, as ImportTuples validates it, so that every invalid
+	// tuple is reported rather than the first.
+	var failures []WriteFailure
+	valid := writes
+	if len(writes) > 0 {
+		validate, err := ds.importValidator(ctx, store)
+		if err != nil {
+			return err
+		}
+		valid = make(storage.Writes, 0, len(writes))
+		for _, write := range writes {
+			if err := validate(write); err != nil {
+				failures = append(failures, WriteFailure{TupleKey: write, Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE, Err: err})
+				continue
+			}
+			valid = append(valid, write)
+		}
+	}
+
+	if opts.Atomic {
+		if len(failures) > 0 {
+			return &WriteBatchError{Failures: failures}
+		}
+		err := ds.write(ctx, store, "", deletes, valid, nil)
+		var offender *tupleError
+		if errors.As(err, &offender) {
+			return &WriteBatchError{Failures: []WriteFailure{{TupleKey: offender.tupleKey, Operation: offender.operation, Err: err}}}
+		}
+		return err
+	}
+
+	strict, err := ds.strictTupleValidationFor(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	release, err := ds.acquireWriteSlot(ctx, store)
+	if err != nil {
+		return fmt.Errorf("wait for write slot: %w", err)
+	}
+	defer release()
+
+	applied, applyFailures, err := ds.applyPartialWrites(ctx, store, deletes, valid, ds.idempotentDeletes && !strict)
+	if err != nil {
+		return unavailableError(writeConcernError(err))
+	}
+	failures = append(failures, applyFailures...)
+
+	setResultCount(span, applied)
+	if len(failures) > 0 {
+		return &WriteBatchError{Failures: failures, Applied: applied}
+	}
+	return nil
+}
+
+// applyPartialWrites applies the deletes and writes that can be applied and records them in the
+// changelog as WriteModeIntent does, so without a transaction. It returns how many changes were
+// applied and the tuples that failed: deletes of tuples that don't exist, unless
+// skipMissingDeletes, and writes of tuples that are stored or that the server rejected, from an
+// unordered InsertMany.
+func (ds *Datastore) applyPartialWrites(
+	ctx context.Context,
+	store string,
+	deletes storage.Deletes,
+	writes storage.Writes,
+	skipMissingDeletes bool,
+) (int, []WriteFailure, error) {
+	collection := ds.writeCollection(TuplesCollection)
+	changelogCollection := ds.writeCollection(ChangelogCollection)
+	now := primitive.NewDateTimeFromTime(time.Now())
+
+	var failures []WriteFailure
+	newChange := func(doc *TupleDocument, operation openfgav1.TupleOperation) *ChangelogDocument {
+		change := docToChange(doc, operation, now)
+		change.Pending = true
+		return change
+	}
+
+	var deleteChanges []*ChangelogDocument
+	var deleteFilter bson.A
+	if len(deletes) > 0 {
+		filters := make(bson.A, 0, len(deletes))
+		for _, del := range deletes {
+			filters = append(filters, exactTupleFilter(store, del.GetObject(), del.GetRelation(), del.GetUser()))
+		}
+		var existing map[string]*TupleDocument
+		err := ds.retry(ctx, func() (err error) {
+			existing, err = findTupleDocuments(ctx, collection, store, filters, ds.deleteBatchSize)
+			return err
+		})
+		if err != nil {
+			return 0, nil, fmt.Errorf("find tuples for delete: %w", err)
+		}
+
+		for i, del := range deletes {
+			key := tupleUtils.TupleKeyToString(del)
+			existingDoc, ok := existing[key]
+			if !ok {
+				// Missing, or deleted twice in the same batch.
+				if !skipMissingDeletes {
+					failures = append(failures, WriteFailure{
+						TupleKey:  tupleUtils.NewTupleKey(del.GetObject(), del.GetRelation(), del.GetUser()),
+						Operation: openfgav1.TupleOperation_TUPLE_OPERATION_DELETE,
+						Err:       storage.InvalidWriteInputError(del, openfgav1.TupleOperation_TUPLE_OPERATION_DELETE),
+					})
+				}
+				continue
+			}
+			delete(existing, key)
+			deleteFilter = append(deleteFilter, filters[i])
+			deleteChanges = append(deleteChanges, newChange(existingDoc, openfgav1.TupleOperation_TUPLE_OPERATION_DELETE))
+		}
+	}
+
+	var keys []*openfgav1.TupleKey
+	var docs []interface{}
+	var writeChanges []*ChangelogDocument
+	if len(writes) > 0 {
+		filters := make(bson.A, 0, len(writes))
+		for _, write := range writes {
+			filters = append(filters, exactTupleFilter(store, write.GetObject(), write.GetRelation(), write.GetUser()))
+		}
+		var existing map[string]*TupleDocument
+		err := ds.retry(ctx, func() (err error) {
+			existing, err = findTupleDocuments(ctx, collection, store, filters, 0)
+			return err
+		})
+		if err != nil {
+			return 0, nil, fmt.Errorf("find existing tuples: %w", err)
+		}
+		// Tuples deleted by this batch may be written again, so they don't count as existing.
+		for _, del := range deletes {
+			delete(existing, tupleUtils.TupleKeyToString(del))
+		}
+
+		for _, write := range writes {
+			if _, ok := existing[tupleUtils.TupleKeyToString(write)]; ok {
+				failures = append(failures, WriteFailure{TupleKey: write, Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE, Err: duplicateTupleError(write)})
+				continue
+			}
+			doc, err := tupleKeyToDoc(store, write)
+			if err != nil {
+				failures = append(failures, WriteFailure{TupleKey: write, Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE, Err: err})
+				continue
+			}
+			keys = append(keys, write)
+			docs = append(docs, doc)
+			writeChanges = append(writeChanges, newChange(doc, openfgav1.TupleOperation_TUPLE_OPERATION_WRITE))
+		}
+	}
+
+	if len(deleteChanges) == 0 && len(docs) == 0 {
+		return 0, failures, nil
+	}
+
+	intents := make([]interface{}, 0, len(deleteChanges)+len(writeChanges))
+	for _, change := range deleteChanges {
+		intents = append(intents, change)
+	}
+	for _, change := range writeChanges {
+		intents = append(intents, change)
+	}
+	if _, err := changelogCollection.InsertMany(ctx, intents); err != nil {
+		return 0, nil, fmt.Errorf("insert changelog intents: %w", err)
+	}
+
+	if err := deleteTupleDocuments(ctx, collection, store, deleteFilter, ds.deleteBatchSize); err != nil {
+		return 0, nil, err
+	}
+
+	// The indexes of the documents the server rejected; the others were inserted.
+	rejected := map[int]bool{}
+	if len(docs) > 0 {
+		_, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+		var bulkErr mongo.BulkWriteException
+		switch {
+		case err == nil:
+		case errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil && len(bulkErr.WriteErrors) > 0:
+			for _, writeErr := range bulkErr.WriteErrors {
+				if writeErr.Index < 0 || writeErr.Index >= len(keys) {
+					return 0, nil, fmt.Errorf("insert tuples: %w", err)
+				}
+				rejected[writeErr.Index] = true
+				failure := WriteFailure{TupleKey: keys[writeErr.Index], Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE, Err: writeErr}
+				if mongo.IsDuplicateKeyError(writeErr) {
+					failure.Err = duplicateTupleError(keys[writeErr.Index])
+				}
+				failures = append(failures, failure)
+			}
+		default:
+			return 0, nil, fmt.Errorf("insert tuples: %w", err)
+		}
+	}
+
+	confirmed := make([]string, 0, len(intents)-len(rejected))
+	var dropped []string
+	for _, change := range deleteChanges {
+		confirmed = append(confirmed, change.ULID)
+	}
+	for i, change := range writeChanges {
+		if rejected[i] {
+			dropped = append(dropped, change.ULID)
+			continue
+		}
+		confirmed = append(confirmed, change.ULID)
+	}
+
+	if len(dropped) > 0 {
+		if _, err := changelogCollection.DeleteMany(ctx, bson.M{"store": store, "ulid": bson.M{"$in": dropped}}); err != nil {
+			return 0, nil, fmt.Errorf("drop changelog intents: %w", err)
+		}
+	}
+	if len(confirmed) > 0 {
+		_, err := changelogCollection.UpdateMany(ctx, bson.M{"store": store, "ulid": bson.M{"$in": confirmed}}, bson.M{"$unset": bson.M{"pending": ""}})
+		if err != nil {
+			return 0, nil, fmt.Errorf("confirm changelog entries: %w", err)
+		}
+	}
+
+	return len(confirmed), failures, nil
+}
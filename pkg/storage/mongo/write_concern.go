@@ -0,0 +1,71 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// resolveWriteConcern picks the write concern of tuple, model and assertion writes: the
+// configured one, else the one of the database handle (as set by the URI), else majority.
+func resolveWriteConcern(configured *writeconcern.WriteConcern, database *mongo.Database) *writeconcern.WriteConcern {
+	if configured != nil {
+		return configured
+	}
+	if wc := database.WriteConcern(); wc != nil {
+		return wc
+	}
+	return writeconcern.Majority()
+}
+
+// writeCollection returns a handle on the named collection whose writes use the datastore's
+// write concern. Inside a transaction the driver ignores it in favor of the transaction's.
+func (ds *Datastore) writeCollection(name string) *mongo.Collection {
+	if ds.writeConcern == nil {
+		return ds.collection(name)
+	}
+	return ds.collection(name, options.Collection().SetWriteConcern(ds.writeConcern))
+}
+
+// writeConcernError returns err wrapped in ErrWriteConcernNotSatisfied when the server applied a
+// write but couldn't acknowledge it at the requested write concern, such as when wtimeout passed
+// before enough members had it, and err unchanged otherwise.
+func writeConcernError(err error) error {
+	if err == nil || errors.Is(err, ErrWriteConcernNotSatisfied) {
+		return err
+	}
+
+	var wce *mongo.WriteConcernError
+	var writeErr mongo.WriteException
+	var bulkErr mongo.BulkWriteException
+	switch {
+	case errors.As(err, &writeErr):
+		wce = writeErr.WriteConcernError
+	case errors.As(err, &bulkErr):
+		wce = bulkErr.WriteConcernError
+	}
+	if wce == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrWriteConcernNotSatisfied, err)
+}
+
+// describeFullWriteConcern formats a write concern's w, j and wtimeout options the way they are
+// written in a URI.
+func describeFullWriteConcern(wc *writeconcern.WriteConcern) string {
+	if wc == nil {
+		return "default"
+	}
+	described := describeWriteConcern(wc.W)
+	if wc.Journal != nil {
+		described += ",j:" + strconv.FormatBool(*wc.Journal)
+	}
+	if wc.WTimeout > 0 {
+		described += ",wtimeout:" + wc.WTimeout.String()
+	}
+	return described
+}
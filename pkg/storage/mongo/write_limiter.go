@@ -0,0 +1,28 @@
+package mongo
+
+import (
+	"context"
+	"time"
+)
+
+// acquireWriteSlot waits until the store has fewer than MaxConcurrentWritesPerStore writes in
+// flight and returns the function that releases the slot. Queuing writes to a hot store here is
+// cheaper than letting their transactions collide and retry on write conflicts.
+func (ds *Datastore) acquireWriteSlot(ctx context.Context, store string) (func(), error) {
+	if ds.maxConcurrentWritesPerStore <= 0 {
+		return func() {}, nil
+	}
+
+	limiter, _ := ds.writeLimiters.LoadOrStore(store, make(chan struct{}, ds.maxConcurrentWritesPerStore))
+	slots := limiter.(chan struct{})
+
+	start := time.Now()
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	ds.metrics.observeWriteWait(start)
+
+	return func() { <-slots }, nil
+}
@@ -0,0 +1,95 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// WritePreview reports what a Write would do with a batch, as found by PreviewWrite.
+type WritePreview struct {
+	// Collisions are the writes of tuples that already exist, or that the batch writes twice.
+	// Any collision fails the Write.
+	Collisions []*openfgav1.TupleKey `json:"collisions"`
+	// EffectiveDeletes are the deletes that match a stored tuple.
+	EffectiveDeletes []*openfgav1.TupleKeyWithoutCondition `json:"effective_deletes"`
+	// NoopDeletes are the deletes that match no stored tuple, or that the batch deletes twice.
+	NoopDeletes []*openfgav1.TupleKeyWithoutCondition `json:"noop_deletes"`
+	// Applicable reports whether the Write would succeed: it has no collisions, and no no-op
+	// deletes unless IdempotentDeletes skips them for the store.
+	Applicable bool `json:"applicable"`
+}
+
+// PreviewWrite is a dry run of Write: it validates the batch exactly as Write does, returning the
+// same errors for a batch that is too large, malformed or not allowed by the model, then reports
+// which writes collide with stored tuples and which deletes would take effect, without changing
+// the database. Both are checked with a single query. The preview holds no lock, so a concurrent
+// Write can change the outcome before the batch is written.
+func (ds *Datastore) PreviewWrite(
+	ctx context.Context,
+	store string,
+	deletes storage.Deletes,
+	writes storage.Writes,
+) (_ *WritePreview, err error) {
+	ctx, span := ds.startTrace(ctx, "PreviewWrite", storeAttributes(store, TuplesCollection)...)
+	defer func() { endTrace(span, err) }()
+
+	if err := ds.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	deletes, writes, skipMissingDeletes, err := ds.validateWrite(ctx, store, deletes, writes)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &WritePreview{Applicable: true}
+	if len(deletes) == 0 && len(writes) == 0 {
+		return preview, nil
+	}
+
+	filters := make(bson.A, 0, len(deletes)+len(writes))
+	for _, del := range deletes {
+		filters = append(filters, exactTupleFilter(store, del.GetObject(), del.GetRelation(), del.GetUser()))
+	}
+	for _, write := range writes {
+		filters = append(filters, exactTupleFilter(store, write.GetObject(), write.GetRelation(), write.GetUser()))
+	}
+
+	var existing map[string]*TupleDocument
+	err = ds.retry(ctx, func() (err error) {
+		existing, err = findTupleDocuments(ctx, ds.collection(TuplesCollection), store, filters, 0)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find tuples: %w", err)
+	}
+
+	// As in Write, a tuple stops existing once the batch deletes it, and may then be written again.
+	for _, del := range deletes {
+		key := tupleUtils.TupleKeyToString(del)
+		if _, ok := existing[key]; !ok {
+			preview.NoopDeletes = append(preview.NoopDeletes, del)
+			continue
+		}
+		delete(existing, key)
+		preview.EffectiveDeletes = append(preview.EffectiveDeletes, del)
+	}
+	for _, write := range writes {
+		key := tupleUtils.TupleKeyToString(write)
+		if _, ok := existing[key]; ok {
+			preview.Collisions = append(preview.Collisions, write)
+			continue
+		}
+		existing[key] = nil
+	}
+
+	preview.Applicable = len(preview.Collisions) == 0 && (len(preview.NoopDeletes) == 0 || skipMissingDeletes)
+	return preview, nil
+}